@@ -1,17 +1,19 @@
 package database
 
 import (
+	"time"
+
 	"watgbridge/state"
 
 	"go.mau.fi/whatsmeow/types"
 )
 
-func MsgIdAddNewPair(waMsgId, participantId, waChatId string, tgChatId, tgMsgId, tgThreadId int64) error {
+func MsgIdAddNewPair(account, waMsgId, participantId, waChatId string, tgChatId, tgMsgId, tgThreadId int64) error {
 
 	db := state.State.Database
 
 	var bridgePair MsgIdPair
-	res := db.Where("id = ? AND wa_chat_id = ?", waMsgId, waChatId).Find(&bridgePair)
+	res := db.Where("id = ? AND account = ? AND wa_chat_id = ?", waMsgId, account, waChatId).Find(&bridgePair)
 	if res.Error != nil {
 		return res.Error
 	}
@@ -28,6 +30,7 @@ func MsgIdAddNewPair(waMsgId, participantId, waChatId string, tgChatId, tgMsgId,
 	// else
 	res = db.Create(&MsgIdPair{
 		ID:            waMsgId,
+		Account:       account,
 		ParticipantId: participantId,
 		WaChatId:      waChatId,
 		TgChatId:      tgChatId,
@@ -37,12 +40,12 @@ func MsgIdAddNewPair(waMsgId, participantId, waChatId string, tgChatId, tgMsgId,
 	return res.Error
 }
 
-func MsgIdGetTgFromWa(waMsgId, waChatId string) (int64, int64, int64, error) {
+func MsgIdGetTgFromWa(account, waMsgId, waChatId string) (int64, int64, int64, error) {
 
 	db := state.State.Database
 
 	var bridgePair MsgIdPair
-	res := db.Where("id = ? AND wa_chat_id = ?", waMsgId, waChatId).Find(&bridgePair)
+	res := db.Where("id = ? AND account = ? AND wa_chat_id = ?", waMsgId, account, waChatId).Find(&bridgePair)
 
 	return bridgePair.TgChatId, bridgePair.TgThreadId, bridgePair.TgMsgId, res.Error
 }
@@ -52,7 +55,9 @@ func MsgIdGetWaFromTg(tgChatId, tgMsgId, tgThreadId int64) (msgId, participantId
 	db := state.State.Database
 
 	var bridgePair MsgIdPair
-	res := db.Where("tg_chat_id = ? AND tg_msg_id = ? AND tg_thread_id = ?", tgChatId, tgMsgId, tgThreadId).Find(&bridgePair)
+	// A digest is paired with all the messages in it, replies to it go to the last one
+	res := db.Where("tg_chat_id = ? AND tg_msg_id = ? AND tg_thread_id = ?", tgChatId, tgMsgId, tgThreadId).
+		Order("created_at DESC").Limit(1).Find(&bridgePair)
 
 	return bridgePair.ID, bridgePair.ParticipantId, bridgePair.WaChatId, res.Error
 }
@@ -73,12 +78,12 @@ func MsgIdDropAllPairs() error {
 	return res.Error
 }
 
-func ChatThreadAddNewPair(waChatId string, tgChatId, tgThreadId int64) error {
+func ChatThreadAddNewPair(account, waChatId string, tgChatId, tgThreadId int64) error {
 
 	db := state.State.Database
 
 	var chatPair ChatThreadPair
-	res := db.Where("id = ? AND tg_chat_id = ?", waChatId, tgChatId).Find(&chatPair)
+	res := db.Where("id = ? AND account = ? AND tg_chat_id = ?", waChatId, account, tgChatId).Find(&chatPair)
 	if res.Error != nil {
 		return res.Error
 	}
@@ -93,18 +98,19 @@ func ChatThreadAddNewPair(waChatId string, tgChatId, tgThreadId int64) error {
 	// else
 	res = db.Create(&ChatThreadPair{
 		ID:         waChatId,
+		Account:    account,
 		TgChatId:   tgChatId,
 		TgThreadId: tgThreadId,
 	})
 	return res.Error
 }
 
-func ChatThreadGetTgFromWa(waChatId string, tgChatId int64) (int64, bool, error) {
+func ChatThreadGetTgFromWa(account, waChatId string, tgChatId int64) (int64, bool, error) {
 
 	db := state.State.Database
 
 	var chatPair ChatThreadPair
-	res := db.Where("id = ? AND tg_chat_id = ?", waChatId, tgChatId).Find(&chatPair)
+	res := db.Where("id = ? AND account = ? AND tg_chat_id = ?", waChatId, account, tgChatId).Find(&chatPair)
 
 	found := (chatPair.ID == waChatId && chatPair.TgChatId == tgChatId)
 	return chatPair.TgThreadId, found, res.Error
@@ -138,6 +144,307 @@ func ChatThreadDropAllPairs() error {
 	return res.Error
 }
 
+func ChatSettingsGet(account, waChatId string) (ChatSettings, error) {
+
+	db := state.State.Database
+
+	var settings ChatSettings
+	res := db.Where("id = ? AND account = ?", waChatId, account).Find(&settings)
+
+	return settings, res.Error
+}
+
+// ChatSettingsSet updates a single setting of the chat, the column being the
+// name of the field in ChatSettings. A nil value resets it to the default.
+func ChatSettingsSet(account, waChatId, column string, value interface{}) error {
+
+	db := state.State.Database
+
+	var settings ChatSettings
+	res := db.Where("id = ? AND account = ?", waChatId, account).Find(&settings)
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if settings.ID != waChatId {
+		res = db.Create(&ChatSettings{
+			ID:      waChatId,
+			Account: account,
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+	}
+
+	res = db.Model(&ChatSettings{}).Where("id = ? AND account = ?", waChatId, account).Update(column, value)
+	return res.Error
+}
+
+func ChatSettingsReset(account, waChatId string) error {
+
+	db := state.State.Database
+	res := db.Where("id = ? AND account = ?", waChatId, account).Delete(&ChatSettings{})
+
+	return res.Error
+}
+
+// ChatSettingsGetExpiredMutes returns the settings of the chats whose timed mutes have ended
+func ChatSettingsGetExpiredMutes() ([]ChatSettings, error) {
+
+	db := state.State.Database
+
+	var settings []ChatSettings
+	res := db.Where("muted = ? AND muted_until <= ?", true, time.Now().UTC()).Find(&settings)
+
+	return settings, res.Error
+}
+
+// ChatSettingsGetDigestChats returns the settings of the chats in digest mode
+func ChatSettingsGetDigestChats() ([]ChatSettings, error) {
+
+	db := state.State.Database
+
+	var settings []ChatSettings
+	res := db.Where("digest = ?", true).Find(&settings)
+
+	return settings, res.Error
+}
+
+func HeldMessageAdd(account, waChatId, waMsgId, senderId string, timestamp time.Time, mediaType, text string) error {
+
+	db := state.State.Database
+	res := db.Create(&HeldMessage{
+		Account:   account,
+		WaChatId:  waChatId,
+		WaMsgId:   waMsgId,
+		SenderId:  senderId,
+		Timestamp: timestamp,
+		MediaType: mediaType,
+		Text:      text,
+	})
+
+	return res.Error
+}
+
+func HeldMessageGetAll(account, waChatId string) ([]HeldMessage, error) {
+
+	db := state.State.Database
+
+	var heldMessages []HeldMessage
+	res := db.Where("account = ? AND wa_chat_id = ?", account, waChatId).Order("timestamp").Find(&heldMessages)
+
+	return heldMessages, res.Error
+}
+
+func HeldMessageDelete(heldMessages []HeldMessage) error {
+
+	if len(heldMessages) == 0 {
+		return nil
+	}
+
+	db := state.State.Database
+	res := db.Delete(&heldMessages)
+
+	return res.Error
+}
+
+func AlertRuleAdd(rule *AlertRule) error {
+
+	db := state.State.Database
+	res := db.Create(rule)
+
+	return res.Error
+}
+
+func AlertRuleGetAll() ([]AlertRule, error) {
+
+	db := state.State.Database
+
+	var rules []AlertRule
+	res := db.Order("id").Find(&rules)
+
+	return rules, res.Error
+}
+
+// AlertRuleDelete deletes the rule with the given ID, returning false if there was none
+func AlertRuleDelete(id uint) (bool, error) {
+
+	db := state.State.Database
+	res := db.Delete(&AlertRule{}, id)
+
+	return res.RowsAffected > 0, res.Error
+}
+
+func StatusHeaderGet(account, senderId, day string, tgChatId, tgThreadId int64) (StatusHeader, error) {
+
+	db := state.State.Database
+
+	var header StatusHeader
+	res := db.Where("account = ? AND sender_id = ? AND day = ? AND tg_chat_id = ? AND tg_thread_id = ?",
+		account, senderId, day, tgChatId, tgThreadId).Find(&header)
+
+	return header, res.Error
+}
+
+func StatusHeaderGetById(id uint) (StatusHeader, error) {
+
+	db := state.State.Database
+
+	var header StatusHeader
+	res := db.Where("id = ?", id).Find(&header)
+
+	return header, res.Error
+}
+
+func StatusHeaderAdd(header *StatusHeader) error {
+
+	db := state.State.Database
+	res := db.Create(header)
+
+	return res.Error
+}
+
+func StatusHeaderSetMsgId(id uint, tgMsgId int64) error {
+
+	db := state.State.Database
+	res := db.Model(&StatusHeader{}).Where("id = ?", id).Update("tg_msg_id", tgMsgId)
+
+	return res.Error
+}
+
+func StatusHeaderDelete(id uint) error {
+
+	db := state.State.Database
+	res := db.Where("id = ?", id).Delete(&StatusHeader{})
+
+	return res.Error
+}
+
+// MsgIdGetStatusesInRange returns the pairs of the statuses bridged into the topic
+// between the given times
+func MsgIdGetStatusesInRange(account string, tgChatId, tgThreadId int64, from, to time.Time) ([]MsgIdPair, error) {
+
+	db := state.State.Database
+
+	var bridgePairs []MsgIdPair
+	res := db.Where("account = ? AND wa_chat_id = ? AND tg_chat_id = ? AND tg_thread_id = ? AND created_at >= ? AND created_at < ?",
+		account, "status@broadcast", tgChatId, tgThreadId, from, to).Find(&bridgePairs)
+
+	return bridgePairs, res.Error
+}
+
+// DisappearingTimerGet returns the timer of the chat, and false if it is not known
+func DisappearingTimerGet(account, waChatId string) (uint32, bool, error) {
+
+	db := state.State.Database
+
+	var timer DisappearingTimer
+	res := db.Where("id = ? AND account = ?", waChatId, account).Find(&timer)
+
+	return timer.Seconds, timer.ID == waChatId, res.Error
+}
+
+func DisappearingTimerSet(account, waChatId string, seconds uint32) error {
+
+	db := state.State.Database
+	res := db.Save(&DisappearingTimer{
+		ID:      waChatId,
+		Account: account,
+		Seconds: seconds,
+	})
+
+	return res.Error
+}
+
+func ExpiringCopyAdd(tgChatId, tgMsgId int64, expiresAt time.Time) error {
+
+	db := state.State.Database
+	res := db.Create(&ExpiringCopy{
+		TgChatId:  tgChatId,
+		TgMsgId:   tgMsgId,
+		ExpiresAt: expiresAt.UTC(),
+	})
+
+	return res.Error
+}
+
+// ExpiringCopyGetExpired returns the copies whose messages have expired
+func ExpiringCopyGetExpired() ([]ExpiringCopy, error) {
+
+	db := state.State.Database
+
+	var copies []ExpiringCopy
+	res := db.Where("expires_at <= ?", time.Now().UTC()).Find(&copies)
+
+	return copies, res.Error
+}
+
+func ExpiringCopyDelete(id uint) error {
+
+	db := state.State.Database
+	res := db.Delete(&ExpiringCopy{}, id)
+
+	return res.Error
+}
+
+func StickerCacheGet(key string) (StickerCacheEntry, error) {
+
+	db := state.State.Database
+
+	var entry StickerCacheEntry
+	res := db.Where("id = ?", key).Find(&entry)
+
+	return entry, res.Error
+}
+
+func StickerCacheSave(entry *StickerCacheEntry) error {
+
+	db := state.State.Database
+	res := db.Save(entry)
+
+	return res.Error
+}
+
+// StickerCacheGetAll returns the entries, least recently used first
+func StickerCacheGetAll() ([]StickerCacheEntry, error) {
+
+	db := state.State.Database
+
+	var entries []StickerCacheEntry
+	res := db.Order("last_used ASC").Find(&entries)
+
+	return entries, res.Error
+}
+
+func StickerCacheDelete(key string) error {
+
+	db := state.State.Database
+	res := db.Where("id = ?", key).Delete(&StickerCacheEntry{})
+
+	return res.Error
+}
+
+func WaAccountDeviceGet(account string) (string, error) {
+
+	db := state.State.Database
+
+	var accountDevice WaAccountDevice
+	res := db.Where("account = ?", account).Find(&accountDevice)
+
+	return accountDevice.Jid, res.Error
+}
+
+func WaAccountDeviceSet(account, jid string) error {
+
+	db := state.State.Database
+	res := db.Save(&WaAccountDevice{
+		Account: account,
+		Jid:     jid,
+	})
+
+	return res.Error
+}
+
 func ContactNameAddNew(waUserId, firstName, fullName, pushName, businessName string) error {
 	db := state.State.Database
 
@@ -182,6 +489,9 @@ func ContactNameBulkAddOrUpdate(contacts map[types.JID]types.ContactInfo) error
 			FullName:     v.FullName,
 		})
 	}
+	if len(contactNames) == 0 {
+		return nil
+	}
 
 	res := db.Save(&contactNames)
 	if res.Error != nil {
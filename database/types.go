@@ -1,10 +1,18 @@
 package database
 
-import "watgbridge/state"
+import (
+	"fmt"
+	"time"
+
+	"watgbridge/state"
+
+	"gorm.io/gorm"
+)
 
 type MsgIdPair struct {
 	// WhatsApp
 	ID            string `gorm:"primaryKey;"` // Message ID
+	Account       string `gorm:"primaryKey;"` // Name of the bridged WhatsApp account
 	ParticipantId string // Sender JID
 	WaChatId      string // Chat JID
 
@@ -12,11 +20,17 @@ type MsgIdPair struct {
 	TgChatId   int64
 	TgThreadId int64
 	TgMsgId    int64
+
+	CreatedAt time.Time
 }
 
+// ChatThreadPair is the topic of a WhatsApp chat in a Telegram chat. A WhatsApp chat
+// can have topics in more than one Telegram chat, like the system topics when routes
+// are used.
 type ChatThreadPair struct {
 	ID         string `gorm:"primaryKey;"` // WhatsApp Chat ID
-	TgChatId   int64  // Telegram Chat ID
+	Account    string `gorm:"primaryKey;"` // Name of the bridged WhatsApp account
+	TgChatId   int64  `gorm:"primaryKey;"` // Telegram Chat ID
 	TgThreadId int64  // Telegram Thread ID (Topics)
 }
 
@@ -28,7 +42,227 @@ type ContactName struct {
 	BusinessName string
 }
 
+// ChatSettings overrides the bridging settings from the config file for a single
+// WhatsApp chat. Settings left as nil fall back to the config file.
+type ChatSettings struct {
+	ID      string `gorm:"primaryKey;"` // WhatsApp Chat JID
+	Account string `gorm:"primaryKey;"` // Name of the bridged WhatsApp account
+
+	Muted             *bool
+	MutedUntil        *time.Time // The mute is indefinite if nil
+	MuteMode          *string    // "drop" or "hold"
+	MuteAllowMentions *bool
+	StatusMuted       *bool
+	SkipDocuments     *bool
+	SkipImages        *bool
+	SkipGIFs          *bool
+	SkipVideos        *bool
+	SkipVoiceNotes    *bool
+	SkipAudios        *bool
+	SkipStickers      *bool
+	SkipContacts      *bool
+	SkipLocations     *bool
+	HeaderStyle       *string // "full" or "compact"
+	ReadReceipts      *string // "never", "on_bridge" or "on_reply"
+	MentionAlerts     *bool
+	Digest            *bool
+}
+
+// HeldMessage is a message received while its chat was muted or in digest mode,
+// kept to be sent later as a part of a digest
+type HeldMessage struct {
+	ID        uint   `gorm:"primaryKey;"`
+	Account   string // Name of the bridged WhatsApp account
+	WaChatId  string // Chat JID
+	WaMsgId   string // Message ID
+	SenderId  string // Sender JID
+	Timestamp time.Time
+	MediaType string // Empty for text messages
+	Text      string // Text or caption of the message
+}
+
+// AlertRule is a keyword or regex whose matches in WhatsApp messages are copied
+// to the alerts topic
+type AlertRule struct {
+	ID      uint `gorm:"primaryKey;"`
+	Pattern string
+	IsRegex bool
+	Chats   string // Comma separated users/groups the rule is limited to, empty for all
+	Senders string // Comma separated senders the rule is limited to, empty for all
+}
+
+// StatusHeader is the message in the status topic which the statuses posted by a
+// contact on a day are sent as replies to
+type StatusHeader struct {
+	ID         uint   `gorm:"primaryKey;"`
+	Account    string // Name of the bridged WhatsApp account
+	SenderId   string // JID of the contact
+	Day        string // Local date, like 2006-01-02
+	TgChatId   int64
+	TgThreadId int64
+	TgMsgId    int64
+	CreatedAt  time.Time
+}
+
+// DisappearingTimer is the disappearing messages timer of a chat, learnt from its
+// messages and setting changes
+type DisappearingTimer struct {
+	ID      string `gorm:"primaryKey;"` // WhatsApp Chat JID
+	Account string `gorm:"primaryKey;"` // Name of the bridged WhatsApp account
+	Seconds uint32 // Zero if disappearing messages are off
+}
+
+// ExpiringCopy is a Telegram copy of a disappearing message, to be deleted when the
+// message expires on WhatsApp
+type ExpiringCopy struct {
+	ID        uint `gorm:"primaryKey;"`
+	TgChatId  int64
+	TgMsgId   int64
+	ExpiresAt time.Time
+}
+
+// StickerCacheEntry is a converted sticker stored on disk, along with the handles with
+// which it can be sent again without being uploaded
+type StickerCacheEntry struct {
+	ID       string `gorm:"primaryKey;"` // tg:<file_unique_id> or wa:<hex file SHA256>, with the kind of output
+	FileName string // Name of the file in the cache directory
+	Size     int64
+	LastUsed time.Time
+
+	// WhatsApp upload
+	WaUrl           string
+	WaDirectPath    string
+	WaMediaKey      []byte
+	WaFileEncSha256 []byte
+	WaFileSha256    []byte
+	WaUploadedAt    time.Time
+
+	// Telegram
+	TgFileId string
+}
+
+type WaAccountDevice struct {
+	Account string `gorm:"primaryKey;"` // Name of the bridged WhatsApp account
+	Jid     string // JID of the device in the WhatsApp login database
+}
+
 func AutoMigrate() error {
 	db := state.State.Database
-	return db.AutoMigrate(&MsgIdPair{}, &ChatThreadPair{}, &ContactName{})
+
+	if err := migrateToAccountScopedTables(); err != nil {
+		return err
+	}
+	if err := migrateChatThreadPairsKey(); err != nil {
+		return err
+	}
+
+	return db.AutoMigrate(&MsgIdPair{}, &ChatThreadPair{}, &ContactName{}, &WaAccountDevice{}, &ChatSettings{}, &HeldMessage{}, &AlertRule{}, &StatusHeader{}, &DisappearingTimer{}, &ExpiringCopy{}, &StickerCacheEntry{})
+}
+
+// migrateToAccountScopedTables recreates the tables from before multiple accounts
+// were supported, since the primary keys have changed, and assigns the existing
+// rows to the default account
+func migrateToAccountScopedTables() error {
+	db := state.State.Database
+
+	legacyTables := []struct {
+		model   interface{}
+		table   string
+		columns string
+	}{
+		{&MsgIdPair{}, "msg_id_pairs", "id, participant_id, wa_chat_id, tg_chat_id, tg_thread_id, tg_msg_id"},
+		{&ChatThreadPair{}, "chat_thread_pairs", "id, tg_chat_id, tg_thread_id"},
+	}
+
+	for _, legacy := range legacyTables {
+		var (
+			migrator    = db.Migrator()
+			backupTable = legacy.table + "_legacy"
+		)
+		if !migrator.HasTable(backupTable) && (!migrator.HasTable(legacy.table) || migrator.HasColumn(legacy.model, "Account")) {
+			continue
+		}
+
+		err := recreateTable(legacy.model, legacy.table, backupTable, legacy.columns, "account", state.DefaultWhatsAppAccount)
+		if err != nil {
+			return fmt.Errorf("failed to migrate table '%s' to support multiple accounts : %s", legacy.table, err)
+		}
+	}
+
+	return nil
+}
+
+// migrateChatThreadPairsKey adds the Telegram chat to the primary key of the topics of
+// chats, which only had the WhatsApp chat and the account in it before routes were
+// added
+func migrateChatThreadPairsKey() error {
+	var (
+		db          = state.State.Database
+		migrator    = db.Migrator()
+		table       = "chat_thread_pairs"
+		backupTable = "chat_thread_pairs_keyless"
+	)
+
+	if !migrator.HasTable(backupTable) {
+		if !migrator.HasTable(table) {
+			return nil
+		}
+		columnTypes, err := migrator.ColumnTypes(&ChatThreadPair{})
+		if err != nil {
+			return fmt.Errorf("failed to read columns of table '%s' : %s", table, err)
+		}
+		for _, columnType := range columnTypes {
+			if isPrimaryKey, _ := columnType.PrimaryKey(); columnType.Name() == "tg_chat_id" && isPrimaryKey {
+				return nil
+			}
+		}
+	}
+
+	err := recreateTable(&ChatThreadPair{}, table, backupTable, "id, account, tg_chat_id, tg_thread_id", "", nil)
+	if err != nil {
+		return fmt.Errorf("failed to add the Telegram chat to the primary key of table '%s' : %s", table, err)
+	}
+	return nil
+}
+
+// recreateTable creates the table again from its model, copying the given columns of
+// its rows through a backup table, along with a column set to the same value for all
+// of them if extraColumn is not empty. It runs in a transaction, and a migration which
+// was interrupted where the database could not roll it back, as MySQL commits on
+// schema changes, is resumed from the backup table.
+func recreateTable(model interface{}, table, backupTable, columns, extraColumn string, extraValue interface{}) error {
+	db := state.State.Database
+	resuming := db.Migrator().HasTable(backupTable)
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		migrator := tx.Migrator()
+
+		if !resuming {
+			err := tx.Exec(fmt.Sprintf("CREATE TABLE %s AS SELECT %s FROM %s", backupTable, columns, table)).Error
+			if err != nil {
+				return err
+			}
+		}
+		if migrator.HasTable(table) {
+			if err := migrator.DropTable(table); err != nil {
+				return err
+			}
+		}
+		if err := tx.AutoMigrate(model); err != nil {
+			return err
+		}
+
+		var err error
+		if extraColumn != "" {
+			err = tx.Exec(fmt.Sprintf("INSERT INTO %s (%s, %s) SELECT %s, ? FROM %s",
+				table, columns, extraColumn, columns, backupTable), extraValue).Error
+		} else {
+			err = tx.Exec(fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s",
+				table, columns, columns, backupTable)).Error
+		}
+		if err != nil {
+			return err
+		}
+		return migrator.DropTable(backupTable)
+	})
 }
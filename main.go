@@ -82,6 +82,16 @@ func main() {
 		_ = logger.Sync()
 	}
 
+	if cfg.WhatsApp.DigestIntervalMinutes <= 0 {
+		// The digests would never be sent, holding the messages of chats in digest mode forever
+		logger.Warn("digest_interval_minutes should be positive, using the default instead",
+			zap.Int("digest_interval_minutes", cfg.WhatsApp.DigestIntervalMinutes),
+			zap.Int("default", 60),
+		)
+		cfg.WhatsApp.DigestIntervalMinutes = 60
+		_ = logger.Sync()
+	}
+
 	if cfg.GitExecutable == "" {
 		gitPath, err := exec.LookPath("git")
 		if err != nil && !errors.Is(err, exec.ErrDot) {
@@ -124,26 +134,24 @@ func main() {
 		}
 	}
 
-	if cfg.FfmpegExecutable == "" && !cfg.Telegram.SkipVideoStickers {
-		ffmpegPath, err := exec.LookPath("ffmpeg")
-		if err != nil && !errors.Is(err, exec.ErrDot) {
-			logger.Fatal("failed to set ffmpeg executable path",
-				zap.Error(err),
+	if cfg.FfmpegExecutable == "" {
+		// Without ffmpeg, media is converted only as far as the converter written in Go can
+		if ffmpegPath, err := exec.LookPath("ffmpeg"); err == nil || errors.Is(err, exec.ErrDot) {
+			cfg.FfmpegExecutable = ffmpegPath
+			logger.Info("setting path to ffmpeg executable",
+				zap.String("path", ffmpegPath),
 			)
-		}
+			_ = logger.Sync()
 
-		cfg.FfmpegExecutable = ffmpegPath
-		logger.Info("setting path to ffmpeg executable",
-			zap.String("path", ffmpegPath),
-		)
-		_ = logger.Sync()
-
-		if err = cfg.SaveConfig(); err != nil {
-			logger.Fatal("failed to save config file",
-				zap.Error(err),
-			)
+			if err = cfg.SaveConfig(); err != nil {
+				logger.Fatal("failed to save config file",
+					zap.Error(err),
+				)
+			}
 		}
 	}
+	utils.SetupMediaConverter()
+	utils.SetupLinkPreviews()
 
 	// Setup database
 	db, err := database.Connect()
@@ -168,24 +176,43 @@ func main() {
 	}
 	_ = logger.Sync()
 
+	// Set before the clients connect, as their event handlers skip older messages
+	state.State.StartTime = time.Now().UTC()
+
 	err = whatsapp.NewWhatsAppClient()
 	if err != nil {
 		panic(err)
 	}
 	_ = logger.Sync()
 
-	state.State.StartTime = time.Now().UTC()
-
 	s := gocron.NewScheduler(time.UTC)
 	s.TagsUnique()
-	_, _ = s.Every(1).Hour().Tag("foo").Do(func() {
-		contacts, err := state.State.WhatsAppClient.Store.Contacts.GetAllContacts()
-		if err == nil {
-			_ = database.ContactNameBulkAddOrUpdate(contacts)
+	_, _ = s.Every(1).Hour().Tag("foo").Do(inFlightJob(func() {
+		for _, account := range state.State.WhatsAppAccounts {
+			contacts, err := account.Client.Store.Contacts.GetAllContacts()
+			if err == nil {
+				_ = database.ContactNameBulkAddOrUpdate(contacts)
+			}
 		}
-	})
+	}))
+
+	if _, err = s.Every(1).Minute().Tag("mutes").Do(inFlightJob(utils.WaEndExpiredMutes)); err != nil {
+		logger.Error("failed to schedule ending expired mutes",
+			zap.Error(err),
+		)
+	}
+	if _, err = s.Every(cfg.WhatsApp.DigestIntervalMinutes).Minutes().Tag("digests").Do(inFlightJob(utils.TgSendIntervalDigests)); err != nil {
+		logger.Error("failed to schedule sending digests",
+			zap.Int("digest_interval_minutes", cfg.WhatsApp.DigestIntervalMinutes),
+			zap.Error(err),
+		)
+	}
+	if _, err = s.Every(1).Minute().Tag("expired").Do(inFlightJob(utils.TgDeleteExpiredCopies)); err != nil {
+		logger.Error("failed to schedule deleting expired copies",
+			zap.Error(err),
+		)
+	}
 
-	state.State.WhatsAppClient.AddEventHandler(whatsapp.WhatsAppEventHandler)
 	telegram.AddTelegramHandlers()
 	modules.LoadModuleHandlers()
 
@@ -239,5 +266,6 @@ func main() {
 	}
 SKIP_RESTART:
 
-	state.State.TelegramUpdater.Idle()
+	s.StartAsync()
+	waitForShutdown(s)
 }
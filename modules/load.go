@@ -38,8 +38,10 @@ func LoadModuleHandlers() {
 		}
 	}
 
-	for _, handler := range WhatsAppHandlers {
-		state.State.WhatsAppClient.AddEventHandler(handler)
+	for _, account := range state.State.WhatsAppAccounts {
+		for _, handler := range WhatsAppHandlers {
+			account.Client.AddEventHandler(handler)
+		}
 	}
 
 	if len(state.State.Modules) > 0 {
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"watgbridge/state"
+	"watgbridge/whatsapp"
+
+	"github.com/go-co-op/gocron"
+	"go.uber.org/zap"
+)
+
+// waitForShutdown blocks till SIGINT or SIGTERM is received and then stops the
+// bridge in an order which lets the messages being bridged finish:
+//   - Telegram polling is stopped and the running handlers are waited for, while
+//     WhatsApp is still connected so that they can send their messages
+//   - WhatsApp is disconnected and the running event handlers and scheduled jobs
+//     are waited for
+//   - The database and logger are closed/flushed
+//
+// A second signal makes the process exit right away.
+func waitForShutdown(scheduler *gocron.Scheduler) {
+	var (
+		cfg    = state.State.Config
+		logger = state.State.Logger
+	)
+
+	sigChan := make(chan os.Signal, 2)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+	sig := <-sigChan
+	logger.Info("received signal, shutting down",
+		zap.String("signal", sig.String()),
+		zap.Int("timeout_seconds", cfg.ShutdownTimeoutSeconds),
+	)
+	_ = logger.Sync()
+
+	go func() {
+		sig := <-sigChan
+		logger.Warn("received another signal, exiting without waiting",
+			zap.String("signal", sig.String()),
+		)
+		_ = logger.Sync()
+		os.Exit(1)
+	}()
+
+	deadline := time.Now().Add(time.Duration(cfg.ShutdownTimeoutSeconds) * time.Second)
+
+	scheduler.Stop()
+
+	telegramStopped := make(chan struct{})
+	go func() {
+		if err := state.State.TelegramUpdater.Stop(); err != nil {
+			logger.Error("failed to stop telegram updater",
+				zap.Error(err),
+			)
+		}
+		close(telegramStopped)
+	}()
+	select {
+	case <-telegramStopped:
+		logger.Info("stopped receiving telegram updates")
+	case <-time.After(time.Until(deadline)):
+		logger.Warn("timed out waiting for telegram handlers to finish")
+	}
+
+	whatsapp.Disconnect()
+	if state.State.InFlight.Close(time.Until(deadline)) {
+		logger.Info("disconnected from whatsapp and finished bridging pending messages")
+	} else {
+		logger.Warn("timed out waiting for whatsapp handlers to finish")
+	}
+
+	if sqlDB, err := state.State.Database.DB(); err == nil {
+		if err = sqlDB.Close(); err != nil {
+			logger.Error("failed to close database",
+				zap.Error(err),
+			)
+		}
+	}
+
+	logger.Info("shut down gracefully")
+	_ = logger.Sync()
+}
+
+// inFlightJob wraps a scheduled job so that shutting down waits for it to finish,
+// and skips it once the bridge is shutting down, as the jobs send messages and
+// write to the database
+func inFlightJob(job func()) func() {
+	return func() {
+		if !state.State.InFlight.Begin() {
+			return
+		}
+		defer state.State.InFlight.Done()
+
+		job()
+	}
+}
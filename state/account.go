@@ -0,0 +1,172 @@
+package state
+
+import (
+	"fmt"
+	"regexp"
+
+	"go.mau.fi/whatsmeow"
+	waTypes "go.mau.fi/whatsmeow/types"
+	"golang.org/x/exp/slices"
+)
+
+// Name of the account configured by the top level WhatsApp settings
+const DefaultWhatsAppAccount = "default"
+
+// Chat types which a route can be limited to
+const (
+	RouteChatTypeGroup   = "group"
+	RouteChatTypePrivate = "private"
+	RouteChatTypeStatus  = "status"
+)
+
+// WhatsAppAccount is a single WhatsApp session along with the Telegram
+// group its chats are bridged into
+type WhatsAppAccount struct {
+	Name       string
+	Client     *whatsmeow.Client
+	Connection waConnection
+
+	TargetChatID        int64
+	TagAllAllowedGroups []string
+	IgnoreChats         []string
+	StatusIgnoredChats  []string
+	Routes              []*WhatsAppRoute
+}
+
+type WhatsAppRoute struct {
+	TargetChatID int64
+	Chats        []string
+	ChatType     string
+	NameRegex    *regexp.Regexp
+}
+
+// LoadWhatsAppAccounts builds the list of accounts from the config, the
+// default account being the first one. The clients are set up later.
+func (s *state) LoadWhatsAppAccounts() error {
+	cfg := s.Config
+
+	defaultAccount := &WhatsAppAccount{
+		Name:                DefaultWhatsAppAccount,
+		TargetChatID:        cfg.Telegram.TargetChatID,
+		TagAllAllowedGroups: cfg.WhatsApp.TagAllAllowedGroups,
+		IgnoreChats:         cfg.WhatsApp.IgnoreChats,
+		StatusIgnoredChats:  cfg.WhatsApp.StatusIgnoredChats,
+	}
+	if err := defaultAccount.loadRoutes(cfg.WhatsApp.Routes); err != nil {
+		return err
+	}
+	s.WhatsAppAccounts = []*WhatsAppAccount{defaultAccount}
+
+	for _, accountConfig := range cfg.WhatsApp.Accounts {
+		account := &WhatsAppAccount{
+			Name:                accountConfig.Name,
+			TargetChatID:        accountConfig.TargetChatID,
+			TagAllAllowedGroups: accountConfig.TagAllAllowedGroups,
+			IgnoreChats:         accountConfig.IgnoreChats,
+			StatusIgnoredChats:  accountConfig.StatusIgnoredChats,
+		}
+		if err := account.loadRoutes(accountConfig.Routes); err != nil {
+			return err
+		}
+		s.WhatsAppAccounts = append(s.WhatsAppAccounts, account)
+	}
+
+	return nil
+}
+
+func (a *WhatsAppAccount) loadRoutes(routes []WhatsAppRouteConfig) error {
+	for routeNum, routeConfig := range routes {
+		if routeConfig.TargetChatID == 0 {
+			return fmt.Errorf("route %v of WhatsApp account '%s' does not have a target_chat_id", routeNum+1, a.Name)
+		}
+
+		switch routeConfig.ChatType {
+		case "", RouteChatTypeGroup, RouteChatTypePrivate, RouteChatTypeStatus:
+		default:
+			return fmt.Errorf("route %v of WhatsApp account '%s' has unknown chat_type '%s'", routeNum+1, a.Name, routeConfig.ChatType)
+		}
+
+		route := &WhatsAppRoute{
+			TargetChatID: routeConfig.TargetChatID,
+			Chats:        routeConfig.Chats,
+			ChatType:     routeConfig.ChatType,
+		}
+		if routeConfig.NameRegex != "" {
+			nameRegex, err := regexp.Compile(routeConfig.NameRegex)
+			if err != nil {
+				return fmt.Errorf("route %v of WhatsApp account '%s' has invalid name_regex : %s", routeNum+1, a.Name, err)
+			}
+			route.NameRegex = nameRegex
+		}
+
+		a.Routes = append(a.Routes, route)
+	}
+
+	return nil
+}
+
+// RouteChat returns the Telegram chat which a WhatsApp chat is bridged into. For
+// statuses, the JID is of the sender. The name is only fetched if a route needs it.
+func (a *WhatsAppAccount) RouteChat(jid waTypes.JID, isStatus bool, getName func() string) int64 {
+	chatType := RouteChatTypePrivate
+	if isStatus {
+		chatType = RouteChatTypeStatus
+	} else if jid.Server == waTypes.GroupServer {
+		chatType = RouteChatTypeGroup
+	}
+
+	var name *string
+	for _, route := range a.Routes {
+		if route.ChatType != "" && route.ChatType != chatType {
+			continue
+		}
+		if len(route.Chats) > 0 && !slices.Contains(route.Chats, jid.User) {
+			continue
+		}
+		if route.NameRegex != nil {
+			if name == nil {
+				fetchedName := getName()
+				name = &fetchedName
+			}
+			if !route.NameRegex.MatchString(*name) {
+				continue
+			}
+		}
+		return route.TargetChatID
+	}
+
+	return a.TargetChatID
+}
+
+// BridgesTgChat tells if the Telegram chat is the target chat of the account or of one of its routes
+func (a *WhatsAppAccount) BridgesTgChat(tgChatId int64) bool {
+	if a.TargetChatID == tgChatId {
+		return true
+	}
+	for _, route := range a.Routes {
+		if route.TargetChatID == tgChatId {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *state) WhatsAppAccountByName(name string) *WhatsAppAccount {
+	for _, account := range s.WhatsAppAccounts {
+		if account.Name == name {
+			return account
+		}
+	}
+	return nil
+}
+
+// WhatsAppAccountByTgChat returns the account bridged into the given Telegram
+// chat. The default account is returned, along with false, for other chats.
+func (s *state) WhatsAppAccountByTgChat(tgChatId int64) (*WhatsAppAccount, bool) {
+	for _, account := range s.WhatsAppAccounts {
+		if account.BridgesTgChat(tgChatId) {
+			return account, true
+		}
+	}
+	return s.WhatsAppAccounts[0], false
+}
@@ -17,6 +17,13 @@ type Config struct {
 	FfmpegExecutable string `yaml:"ffmpeg_executable"`
 	DebugMode        bool   `yaml:"debug_mode"`
 
+	ShutdownTimeoutSeconds int `yaml:"shutdown_timeout_seconds"`
+
+	StickerCache struct {
+		Path      string `yaml:"path"`
+		MaxSizeMB int64  `yaml:"max_size_mb"`
+	} `yaml:"sticker_cache"`
+
 	Telegram struct {
 		BotToken            string  `yaml:"bot_token"`
 		APIURL              string  `yaml:"api_url"`
@@ -26,6 +33,25 @@ type Config struct {
 		SelfHostedAPI       bool    `yaml:"self_hosted_api"`
 		SkipVideoStickers   bool    `yaml:"skip_video_stickers"`
 		SkipSettingCommands bool    `yaml:"skip_setting_commands"`
+		UpdateMode          string  `yaml:"update_mode"`
+		Webhook             struct {
+			ListenAddr  string `yaml:"listen_addr"`
+			PublicURL   string `yaml:"public_url"`
+			URLPath     string `yaml:"url_path"`
+			SecretToken string `yaml:"secret_token"`
+			CertFile    string `yaml:"cert_file"`
+			KeyFile     string `yaml:"key_file"`
+			SelfSigned  bool   `yaml:"self_signed"`
+		} `yaml:"webhook"`
+		SystemTopics struct {
+			Status      string `yaml:"status"`
+			Calls       string `yaml:"calls"`
+			Mentions    string `yaml:"mentions"`
+			TagAlls     string `yaml:"tag_alls"`
+			Revocations string `yaml:"revocations"`
+			Errors      string `yaml:"errors"`
+			Alerts      string `yaml:"alerts"`
+		} `yaml:"system_topics"`
 	} `yaml:"telegram"`
 
 	WhatsApp struct {
@@ -37,6 +63,32 @@ type Config struct {
 			PackName   string `yaml:"pack_name"`
 			AuthorName string `yaml:"author_name"`
 		} `yaml:"sticker_metadata"`
+		StatusPosting struct {
+			BackgroundColor string   `yaml:"background_color"`
+			Font            string   `yaml:"font"`
+			Audience        []string `yaml:"audience"`
+		} `yaml:"status_posting"`
+		Reconnect struct {
+			InitialDelaySeconds int `yaml:"initial_delay_seconds"`
+			MaxDelaySeconds     int `yaml:"max_delay_seconds"`
+			AlertAfterFailures  int `yaml:"alert_after_failures"`
+		} `yaml:"reconnect"`
+		LinkPreviews struct {
+			Enabled        bool   `yaml:"enabled"`
+			TimeoutSeconds int    `yaml:"timeout_seconds"`
+			MaxSizeKB      int64  `yaml:"max_size_kb"`
+			UserAgent      string `yaml:"user_agent"`
+		} `yaml:"link_previews"`
+		Accounts []struct {
+			Name                string                `yaml:"name"`
+			TargetChatID        int64                 `yaml:"target_chat_id"`
+			TagAllAllowedGroups []string              `yaml:"tag_all_allowed_groups"`
+			IgnoreChats         []string              `yaml:"ignore_chats"`
+			StatusIgnoredChats  []string              `yaml:"status_ignored_chats"`
+			Routes              []WhatsAppRouteConfig `yaml:"routes"`
+		} `yaml:"accounts"`
+		Routes []WhatsAppRouteConfig `yaml:"routes"`
+
 		SessionName                    string   `yaml:"session_name"`
 		TagAllAllowedGroups            []string `yaml:"tag_all_allowed_groups"`
 		IgnoreChats                    []string `yaml:"ignore_chats"`
@@ -54,11 +106,28 @@ type Config struct {
 		SendRevokedMessageUpdates      bool     `yaml:"send_revoked_message_updates"`
 		WhatsmeowDebugMode             bool     `yaml:"whatsmeow_debug_mode"`
 		SendMyMessagesFromOtherDevices bool     `yaml:"send_my_messages_from_other_devices"`
+		ReadReceipts                   string   `yaml:"read_receipts"`
+		MentionAlerts                  bool     `yaml:"mention_alerts"`
+		MuteMode                       string   `yaml:"mute_mode"`
+		MuteAllowMentions              bool     `yaml:"mute_allow_mentions"`
+		DigestIntervalMinutes          int      `yaml:"digest_interval_minutes"`
+		StatusLayout                   string   `yaml:"status_layout"`
+		BridgeViewOnce                 bool     `yaml:"bridge_view_once"`
+		DeleteExpiredCopies            bool     `yaml:"delete_expired_copies"`
 	} `yaml:"whatsapp"`
 
 	Database map[string]string `yaml:"database"`
 }
 
+// WhatsAppRouteConfig sends the WhatsApp chats matching all of the given
+// conditions to a Telegram chat other than the target chat of the account
+type WhatsAppRouteConfig struct {
+	TargetChatID int64    `yaml:"target_chat_id"`
+	Chats        []string `yaml:"chats"`
+	ChatType     string   `yaml:"chat_type"`
+	NameRegex    string   `yaml:"name_regex"`
+}
+
 func (cfg *Config) LoadConfig() error {
 	configFilePath := cfg.Path
 
@@ -109,9 +178,34 @@ func (cfg *Config) SaveConfig() error {
 
 func (cfg *Config) SetDefaults() {
 	cfg.TimeZone = "UTC"
+	cfg.ShutdownTimeoutSeconds = 30
+	cfg.StickerCache.Path = "sticker_cache"
+	cfg.StickerCache.MaxSizeMB = 100
+	cfg.Telegram.UpdateMode = "polling"
+	cfg.Telegram.SystemTopics.Status = "Status"
+	cfg.Telegram.SystemTopics.Calls = "Calls"
+	cfg.Telegram.SystemTopics.Mentions = "Mentions"
+	cfg.Telegram.SystemTopics.TagAlls = "Tag alls"
+	cfg.Telegram.SystemTopics.Revocations = "Revocations"
+	cfg.Telegram.SystemTopics.Errors = "Bridge errors"
+	cfg.Telegram.SystemTopics.Alerts = "Alerts"
 	cfg.WhatsApp.SessionName = "Telegram"
 	cfg.WhatsApp.LoginDatabase.Type = "sqlite3"
 	cfg.WhatsApp.LoginDatabase.URL = "file:wawebstore.db?foreign_keys=on"
 	cfg.WhatsApp.StickerMetadata.PackName = "Sticker Pack"
 	cfg.WhatsApp.StickerMetadata.AuthorName = "Ilham M."
+	cfg.WhatsApp.StatusPosting.BackgroundColor = "#128C7E"
+	cfg.WhatsApp.StatusPosting.Font = "sans_serif"
+	cfg.WhatsApp.Reconnect.InitialDelaySeconds = 2
+	cfg.WhatsApp.Reconnect.MaxDelaySeconds = 300
+	cfg.WhatsApp.Reconnect.AlertAfterFailures = 5
+	cfg.WhatsApp.LinkPreviews.Enabled = true
+	cfg.WhatsApp.LinkPreviews.TimeoutSeconds = 5
+	cfg.WhatsApp.LinkPreviews.MaxSizeKB = 1024
+	cfg.WhatsApp.LinkPreviews.UserAgent = "WhatsApp/2.23.2 A"
+	cfg.WhatsApp.ReadReceipts = "never"
+	cfg.WhatsApp.MentionAlerts = true
+	cfg.WhatsApp.MuteMode = "drop"
+	cfg.WhatsApp.DigestIntervalMinutes = 60
+	cfg.WhatsApp.StatusLayout = "per_contact"
 }
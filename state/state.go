@@ -1,6 +1,7 @@
 package state
 
 import (
+	"sync"
 	"time"
 
 	"github.com/PaulSonOfLars/gotgbot/v2"
@@ -22,16 +23,103 @@ type state struct {
 	TelegramUpdater    *ext.Updater
 	TelegramCommands   []gotgbot.BotCommand
 
-	WhatsAppClient *whatsmeow.Client
+	// Client of the default account, kept for the modules
+	WhatsAppClient   *whatsmeow.Client
+	WhatsAppAccounts []*WhatsAppAccount
 
 	Modules []string
 
+	InFlight inFlightWork
+
 	StartTime     time.Time
 	LocalLocation *time.Location
 }
 
 var State state
 
+type WaConnectionStatus string
+
+const (
+	WaConnecting        WaConnectionStatus = "connecting"
+	WaConnected         WaConnectionStatus = "connected"
+	WaReconnecting      WaConnectionStatus = "reconnecting"
+	WaLoggedOut         WaConnectionStatus = "logged out"
+	WaTemporarilyBanned WaConnectionStatus = "temporarily banned"
+	WaStreamReplaced    WaConnectionStatus = "replaced by another session"
+	WaWaitingForQRLogin WaConnectionStatus = "waiting for QR login"
+)
+
+type waConnection struct {
+	lock     sync.RWMutex
+	status   WaConnectionStatus
+	since    time.Time
+	failures int
+}
+
+func (c *waConnection) SetStatus(status WaConnectionStatus, failures int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.status != status {
+		c.since = time.Now().UTC()
+	}
+	c.status = status
+	c.failures = failures
+}
+
+func (c *waConnection) GetStatus() (WaConnectionStatus, time.Time, int) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.status, c.since, c.failures
+}
+
+// inFlightWork tracks the bridging work which should be allowed to
+// complete before the process exits
+type inFlightWork struct {
+	lock   sync.Mutex
+	wg     sync.WaitGroup
+	closed bool
+}
+
+// Begin marks the start of a unit of work. It returns false if the bridge
+// is shutting down, in which case the work should not be started.
+func (w *inFlightWork) Begin() bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.closed {
+		return false
+	}
+	w.wg.Add(1)
+	return true
+}
+
+func (w *inFlightWork) Done() {
+	w.wg.Done()
+}
+
+// Close stops any new work from beginning and waits for the current work to
+// finish. It returns false if the timeout was reached first.
+func (w *inFlightWork) Close(timeout time.Duration) bool {
+	w.lock.Lock()
+	w.closed = true
+	w.lock.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
 func init() {
 	State.Config = &Config{Path: "config.yaml"}
 }
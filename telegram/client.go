@@ -4,6 +4,10 @@ import (
 	"fmt"
 	"math"
 	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
 	"time"
 
 	"watgbridge/state"
@@ -14,6 +18,8 @@ import (
 	"go.uber.org/zap"
 )
 
+var webhookSecretTokenRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
 func NewTelegramClient() error {
 	var (
 		cfg    = state.State.Config
@@ -59,17 +65,34 @@ func NewTelegramClient() error {
 	state.State.TelegramUpdater = updater
 	state.State.TelegramDispatcher = dispatcher
 
-	err = updater.StartPolling(bot, &ext.PollingOpts{
-		DropPendingUpdates: true,
-		GetUpdatesOpts: gotgbot.GetUpdatesOpts{
-			Timeout: 9,
-			RequestOpts: &gotgbot.RequestOpts{
-				Timeout: 10 * time.Second,
+	switch cfg.Telegram.UpdateMode {
+	case "", "polling":
+		_, err = bot.DeleteWebhook(&gotgbot.DeleteWebhookOpts{})
+		if err != nil {
+			return fmt.Errorf("telegram failed to delete existing webhook : %s", err)
+		}
+
+		err = updater.StartPolling(bot, &ext.PollingOpts{
+			DropPendingUpdates: true,
+			GetUpdatesOpts: gotgbot.GetUpdatesOpts{
+				Timeout: 9,
+				RequestOpts: &gotgbot.RequestOpts{
+					Timeout: 10 * time.Second,
+				},
 			},
-		},
-	})
-	if err != nil {
-		return fmt.Errorf("telegram failed to start polling : %s", err)
+		})
+		if err != nil {
+			return fmt.Errorf("telegram failed to start polling : %s", err)
+		}
+
+	case "webhook":
+		err = startWebhook(bot, updater)
+		if err != nil {
+			return fmt.Errorf("telegram failed to start webhook : %s", err)
+		}
+
+	default:
+		return fmt.Errorf("unknown telegram update_mode '%s', should be 'polling' or 'webhook'", cfg.Telegram.UpdateMode)
 	}
 
 	logger.Info("successfully logged into telegram",
@@ -77,7 +100,56 @@ func NewTelegramClient() error {
 		zap.String("name", bot.FirstName),
 		zap.String("username", "@"+bot.Username),
 		zap.String("api_url", bot.GetAPIURL()),
+		zap.String("update_mode", cfg.Telegram.UpdateMode),
 	)
 
 	return nil
 }
+
+func startWebhook(bot *gotgbot.Bot, updater *ext.Updater) error {
+	webhookCfg := state.State.Config.Telegram.Webhook
+
+	if webhookCfg.ListenAddr == "" || webhookCfg.PublicURL == "" {
+		return fmt.Errorf("'listen_addr' and 'public_url' are required in webhook config")
+	}
+	if webhookCfg.SecretToken != "" && !webhookSecretTokenRegex.MatchString(webhookCfg.SecretToken) {
+		return fmt.Errorf("'secret_token' should be 1-256 characters long and contain only A-Z, a-z, 0-9, _ and -")
+	}
+
+	publicURL, err := url.Parse(webhookCfg.PublicURL)
+	if err != nil {
+		return fmt.Errorf("failed to parse 'public_url' : %s", err)
+	}
+
+	urlPath := strings.Trim(webhookCfg.URLPath, "/")
+	if urlPath == "" {
+		urlPath = strings.Trim(publicURL.Path, "/")
+	}
+
+	err = updater.StartWebhook(bot, urlPath, ext.WebhookOpts{
+		ListenAddr:        webhookCfg.ListenAddr,
+		ReadTimeout:       10 * time.Second,
+		ReadHeaderTimeout: 10 * time.Second,
+		CertFile:          webhookCfg.CertFile,
+		KeyFile:           webhookCfg.KeyFile,
+		SecretToken:       webhookCfg.SecretToken,
+	})
+	if err != nil {
+		return err
+	}
+
+	setWebhookOpts := &gotgbot.SetWebhookOpts{
+		DropPendingUpdates: true,
+		SecretToken:        webhookCfg.SecretToken,
+	}
+	if webhookCfg.SelfSigned && webhookCfg.CertFile != "" {
+		certBytes, err := os.ReadFile(webhookCfg.CertFile)
+		if err != nil {
+			return fmt.Errorf("failed to read 'cert_file' : %s", err)
+		}
+		setWebhookOpts.Certificate = certBytes
+	}
+
+	_, err = bot.SetWebhook(webhookCfg.PublicURL, setWebhookOpts)
+	return err
+}
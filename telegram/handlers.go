@@ -9,6 +9,9 @@ import (
 	"os"
 	"os/exec"
 	"path"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -28,14 +31,12 @@ import (
 var commands = []handlers.Command{}
 
 func AddTelegramHandlers() {
-	var (
-		cfg        = state.State.Config
-		dispatcher = state.State.TelegramDispatcher
-	)
+	dispatcher := state.State.TelegramDispatcher
 
 	dispatcher.AddHandlerToGroup(handlers.NewMessage(
 		func(msg *gotgbot.Message) bool {
-			return msg.Chat.Id == cfg.Telegram.TargetChatID
+			_, isTargetChat := state.State.WhatsAppAccountByTgChat(msg.Chat.Id)
+			return isTargetChat
 		}, BridgeTelegramToWhatsAppHandler,
 	), DispatcherForwardHandlerGroup)
 
@@ -54,6 +55,14 @@ func AddTelegramHandlers() {
 		handlers.NewCommand("updateandrestart", UpdateAndRestartHandler),
 		handlers.NewCommand("synctopicnames", SyncTopicNamesHandler),
 		handlers.NewCommand("send", SendToWhatsAppHandler),
+		handlers.NewCommand("chatsettings", ChatSettingsHandler),
+		handlers.NewCommand("mute", MuteChatHandler),
+		handlers.NewCommand("unmute", UnmuteChatHandler),
+		handlers.NewCommand("alerts", AlertsHandler),
+		handlers.NewCommand("recreatetopic", RecreateSystemTopicHandler),
+		handlers.NewCommand("poststatus", PostStatusHandler),
+		handlers.NewCommand("disappearing", DisappearingHandler),
+		handlers.NewCommand("stealsticker", StealStickerHandler),
 		handlers.NewCommand("help", HelpCommandHandler),
 	)
 
@@ -66,6 +75,16 @@ func AddTelegramHandlers() {
 			return strings.HasPrefix(cq.Data, "revoke")
 		}, RevokeCallbackHandler), DispatcherCallbackHandlerGroup)
 
+	dispatcher.AddHandlerToGroup(handlers.NewCallback(
+		func(cq *gotgbot.CallbackQuery) bool {
+			return strings.HasPrefix(cq.Data, "chatsettings")
+		}, ChatSettingsCallbackHandler), DispatcherCallbackHandlerGroup)
+
+	dispatcher.AddHandlerToGroup(handlers.NewCallback(
+		func(cq *gotgbot.CallbackQuery) bool {
+			return strings.HasPrefix(cq.Data, "statusseen")
+		}, StatusSeenCallbackHandler), DispatcherCallbackHandlerGroup)
+
 	state.State.TelegramCommands = append(state.State.TelegramCommands,
 		gotgbot.BotCommand{
 			Command:     "getwagroups",
@@ -115,6 +134,38 @@ func AddTelegramHandlers() {
 			Command:     "send",
 			Description: "Send a message to WhatsApp",
 		},
+		gotgbot.BotCommand{
+			Command:     "chatsettings",
+			Description: "Change the bridging settings of the WhatsApp chat of current thread",
+		},
+		gotgbot.BotCommand{
+			Command:     "mute",
+			Description: "Mute the WhatsApp chat of current thread, optionally for some time",
+		},
+		gotgbot.BotCommand{
+			Command:     "unmute",
+			Description: "Unmute the WhatsApp chat of current thread",
+		},
+		gotgbot.BotCommand{
+			Command:     "alerts",
+			Description: "Add, list or remove the keywords/regexes to be alerted about",
+		},
+		gotgbot.BotCommand{
+			Command:     "recreatetopic",
+			Description: "Create a system topic (like calls or mentions) again if it was deleted",
+		},
+		gotgbot.BotCommand{
+			Command:     "poststatus",
+			Description: "Post the replied to text, photo or video as your WhatsApp status",
+		},
+		gotgbot.BotCommand{
+			Command:     "disappearing",
+			Description: "Get or set the disappearing messages timer of the WhatsApp chat of the topic",
+		},
+		gotgbot.BotCommand{
+			Command:     "stealsticker",
+			Description: "Save the replied to sticker to your Telegram set, or to your WhatsApp if it is from Telegram",
+		},
 		gotgbot.BotCommand{
 			Command:     "help",
 			Description: "Get all the available commands",
@@ -137,8 +188,10 @@ func BridgeTelegramToWhatsAppHandler(b *gotgbot.Bot, c *ext.Context) error {
 		}
 	}
 
+	account, _ := state.State.WhatsAppAccountByTgChat(c.EffectiveChat.Id)
+
 	var (
-		waClient     = state.State.WhatsAppClient
+		waClient     = account.Client
 		msgToForward = c.EffectiveMessage
 		msgToReplyTo = c.EffectiveMessage.ReplyToMessage
 	)
@@ -168,9 +221,17 @@ func BridgeTelegramToWhatsAppHandler(b *gotgbot.Bot, c *ext.Context) error {
 				return err
 			}
 			return nil
+		} else if utils.IsSystemTopic(waChatID) {
+			_, err = utils.TgReplyTextByContext(b, c, "Messages in this topic are not sent to WhatsApp", nil)
+			return err
 		}
 	}
 
+	if stanzaID != "" && !strings.HasSuffix(waChatID, "@broadcast") {
+		waChatJID, _ := utils.WaParseJID(waChatID)
+		utils.WaMarkReadOnReply(account, waChatJID, participantID, stanzaID)
+	}
+
 	// Status Update
 	if strings.HasSuffix(waChatID, "@broadcast") {
 		waChatID = participantID
@@ -181,7 +242,7 @@ func BridgeTelegramToWhatsAppHandler(b *gotgbot.Bot, c *ext.Context) error {
 
 	waChatJID, _ := utils.WaParseJID(waChatID)
 
-	return utils.TgSendToWhatsApp(b, c, msgToForward, msgToReplyTo, waChatJID, participantID, stanzaID, msgToReplyTo != nil && msgToReplyTo.ForumTopicCreated == nil)
+	return utils.TgSendToWhatsApp(b, c, account, msgToForward, msgToReplyTo, waChatJID, participantID, stanzaID, msgToReplyTo != nil && msgToReplyTo.ForumTopicCreated == nil)
 }
 
 func StartCommandHandler(b *gotgbot.Bot, c *ext.Context) error {
@@ -203,6 +264,18 @@ func StartCommandHandler(b *gotgbot.Bot, c *ext.Context) error {
 	)
 	startMessage += fmt.Sprintf(" • <b>Bot Version</b>: <code>%s</code>\n", state.WATGBRIDGE_VERSION)
 
+	for _, account := range state.State.WhatsAppAccounts {
+		waStatus, waStatusSince, waFailures := account.Connection.GetStatus()
+		startMessage += fmt.Sprintf(" • <b>WhatsApp</b> (<code>%s</code>): %s (since %s)\n",
+			html.EscapeString(account.Name),
+			html.EscapeString(string(waStatus)),
+			waStatusSince.In(localLocation).Format(timeFormat),
+		)
+		if waFailures > 0 {
+			startMessage += fmt.Sprintf("   • <b>Failed Reconnection Attempts</b>: %v\n", waFailures)
+		}
+	}
+
 	if len(state.State.Modules) > 0 {
 		startMessage += " • <b>Loaded Modules</b>:\n"
 		for _, module := range state.State.Modules {
@@ -221,7 +294,8 @@ func GetWhatsAppGroupsHandler(b *gotgbot.Bot, c *ext.Context) error {
 		return nil
 	}
 
-	waClient := state.State.WhatsAppClient
+	account, _ := state.State.WhatsAppAccountByTgChat(c.EffectiveChat.Id)
+	waClient := account.Client
 
 	waGroups, err := waClient.GetJoinedGroups()
 	if err != nil {
@@ -333,19 +407,39 @@ func SyncContactsHandler(b *gotgbot.Bot, c *ext.Context) error {
 
 	utils.TgReplyTextByContext(b, c, "Syncing contacts, may take some time...", nil)
 
-	waClient := state.State.WhatsAppClient
+	// Every account is synced even if some fail, the result of each is reported at the end
+	var (
+		accountResults string
+		failed         int
+	)
+	for _, account := range state.State.WhatsAppAccounts {
+		waClient := account.Client
+
+		err := waClient.FetchAppState(appstate.WAPatchCriticalUnblockLow, false, false)
+		if err == nil {
+			var contacts map[waTypes.JID]waTypes.ContactInfo
+			contacts, err = waClient.Store.Contacts.GetAllContacts()
+			if err == nil {
+				err = database.ContactNameBulkAddOrUpdate(contacts)
+			}
+		}
 
-	err := waClient.FetchAppState(appstate.WAPatchCriticalUnblockLow, false, false)
-	if err != nil {
-		return utils.TgReplyWithErrorByContext(b, c, "Failed to sync contacts", err)
+		if err != nil {
+			failed++
+			accountResults += fmt.Sprintf(" • <b>WhatsApp</b> (<code>%s</code>): failed, <code>%s</code>\n",
+				html.EscapeString(account.Name), html.EscapeString(err.Error()))
+		} else {
+			accountResults += fmt.Sprintf(" • <b>WhatsApp</b> (<code>%s</code>): synced\n", html.EscapeString(account.Name))
+		}
 	}
-
-	contacts, err := waClient.Store.Contacts.GetAllContacts()
-	if err == nil {
-		database.ContactNameBulkAddOrUpdate(contacts)
+	replyText := "<b>Successfully synced the contact list</b>\n"
+	if failed > 0 {
+		replyText = fmt.Sprintf("<b>Failed to sync the contact list of %v of %v accounts</b>\n",
+			failed, len(state.State.WhatsAppAccounts))
 	}
+	replyText += accountResults
 
-	_, err = utils.TgReplyTextByContext(b, c, "Successfully synced the contact list", nil)
+	_, err := utils.TgReplyTextByContext(b, c, replyText, nil)
 	return err
 }
 
@@ -368,7 +462,8 @@ func RestartWhatsAppConnectionHandler(b *gotgbot.Bot, c *ext.Context) error {
 		return nil
 	}
 
-	waClient := state.State.WhatsAppClient
+	account, _ := state.State.WhatsAppAccountByTgChat(c.EffectiveChat.Id)
+	waClient := account.Client
 
 	waClient.Disconnect()
 	err := waClient.Connect()
@@ -394,7 +489,8 @@ func JoinInviteLinkHandler(b *gotgbot.Bot, c *ext.Context) error {
 	}
 	inviteLink := args[1]
 
-	waClient := state.State.WhatsAppClient
+	account, _ := state.State.WhatsAppAccountByTgChat(c.EffectiveChat.Id)
+	waClient := account.Client
 
 	groupID, err := waClient.JoinGroupWithLink(inviteLink)
 	if err != nil {
@@ -424,10 +520,11 @@ func SetTargetGroupChatHandler(b *gotgbot.Bot, c *ext.Context) error {
 		return err
 	}
 
+	account, _ := state.State.WhatsAppAccountByTgChat(c.EffectiveChat.Id)
+
 	var (
-		cfg      = state.State.Config
 		groupID  = args[1]
-		waClient = state.State.WhatsAppClient
+		waClient = account.Client
 	)
 
 	groupJID, _ := utils.WaParseJID(groupID)
@@ -437,7 +534,7 @@ func SetTargetGroupChatHandler(b *gotgbot.Bot, c *ext.Context) error {
 	}
 	groupJID = groupInfo.JID
 
-	_, threadFound, err := database.ChatThreadGetTgFromWa(groupJID.String(), cfg.Telegram.TargetChatID)
+	_, threadFound, err := database.ChatThreadGetTgFromWa(account.Name, groupJID.String(), c.EffectiveChat.Id)
 	if err != nil {
 		return utils.TgReplyWithErrorByContext(b, c, "Failed to check database for existing mapping", err)
 	} else if threadFound {
@@ -445,7 +542,7 @@ func SetTargetGroupChatHandler(b *gotgbot.Bot, c *ext.Context) error {
 		return err
 	}
 
-	err = database.ChatThreadAddNewPair(groupJID.String(), cfg.Telegram.TargetChatID, c.EffectiveMessage.MessageThreadId)
+	err = database.ChatThreadAddNewPair(account.Name, groupJID.String(), c.EffectiveChat.Id, c.EffectiveMessage.MessageThreadId)
 	if err != nil {
 		return utils.TgReplyWithErrorByContext(b, c, "Failed to add the mapping in database. Unsuccessful", err)
 	}
@@ -472,14 +569,12 @@ func SetTargetPrivateChatHandler(b *gotgbot.Bot, c *ext.Context) error {
 		return err
 	}
 
-	var (
-		cfg     = state.State.Config
-		groupID = args[1]
-	)
+	account, _ := state.State.WhatsAppAccountByTgChat(c.EffectiveChat.Id)
+	groupID := args[1]
 
 	userJID, _ := utils.WaParseJID(groupID)
 
-	_, threadFound, err := database.ChatThreadGetTgFromWa(userJID.String(), cfg.Telegram.TargetChatID)
+	_, threadFound, err := database.ChatThreadGetTgFromWa(account.Name, userJID.String(), c.EffectiveChat.Id)
 	if err != nil {
 		return utils.TgReplyWithErrorByContext(b, c, "Failed to check database for existing mapping", err)
 	} else if threadFound {
@@ -487,7 +582,7 @@ func SetTargetPrivateChatHandler(b *gotgbot.Bot, c *ext.Context) error {
 		return err
 	}
 
-	err = database.ChatThreadAddNewPair(userJID.String(), cfg.Telegram.TargetChatID, c.EffectiveMessage.MessageThreadId)
+	err = database.ChatThreadAddNewPair(account.Name, userJID.String(), c.EffectiveChat.Id, c.EffectiveMessage.MessageThreadId)
 	if err != nil {
 		return utils.TgReplyWithErrorByContext(b, c, "Failed to add the mapping in database. Unsuccessful", err)
 	}
@@ -510,8 +605,10 @@ func GetProfilePictureHandler(b *gotgbot.Bot, c *ext.Context) error {
 		return err
 	}
 
+	account, _ := state.State.WhatsAppAccountByTgChat(c.EffectiveChat.Id)
+
 	var (
-		waClient = state.State.WhatsAppClient
+		waClient = account.Client
 		userID   = args[1]
 	)
 
@@ -552,6 +649,8 @@ func SyncTopicNamesHandler(b *gotgbot.Bot, c *ext.Context) error {
 		return nil
 	}
 
+	account, _ := state.State.WhatsAppAccountByTgChat(c.EffectiveChat.Id)
+
 	chatThreadPairs, err := database.ChatThreadGetAllPairs(c.EffectiveChat.Id)
 	if err != nil {
 		return utils.TgReplyWithErrorByContext(b, c, "failed to retreive chat thread pairs from database", err)
@@ -563,14 +662,13 @@ func SyncTopicNamesHandler(b *gotgbot.Bot, c *ext.Context) error {
 			tgThreadId = pair.TgThreadId
 		)
 
-		if waChatId == "status@broadcast" {
-			continue
-		}
 		waChatJid, _ := utils.WaParseJID(waChatId)
 
 		var newName string
-		if waChatJid.Server == waTypes.GroupServer {
-			newName = utils.WaGetGroupName(waChatJid)
+		if utils.IsSystemTopic(waChatId) {
+			newName = utils.SystemTopicName(waChatId)
+		} else if waChatJid.Server == waTypes.GroupServer {
+			newName = utils.WaGetGroupName(account, waChatJid)
 		} else {
 			newName = utils.WaGetContactName(waChatJid)
 		}
@@ -630,7 +728,9 @@ func SendToWhatsAppHandler(b *gotgbot.Bot, c *ext.Context) error {
 		return err
 	}
 
-	return utils.TgSendToWhatsApp(b, c, msgToForward, msgToReplyTo, waChatJID, participantID, stanzaID, false)
+	account, _ := state.State.WhatsAppAccountByTgChat(c.EffectiveChat.Id)
+
+	return utils.TgSendToWhatsApp(b, c, account, msgToForward, msgToReplyTo, waChatJID, participantID, stanzaID, false)
 }
 
 func RevokeCommandHandler(b *gotgbot.Bot, c *ext.Context) error {
@@ -645,8 +745,10 @@ func RevokeCommandHandler(b *gotgbot.Bot, c *ext.Context) error {
 		return err
 	}
 
+	account, _ := state.State.WhatsAppAccountByTgChat(c.EffectiveChat.Id)
+
 	var (
-		waClient    = state.State.WhatsAppClient
+		waClient    = account.Client
 		msgToRevoke = c.EffectiveMessage.ReplyToMessage
 		chatId      = c.EffectiveChat.Id
 	)
@@ -672,8 +774,10 @@ func RevokeCallbackHandler(b *gotgbot.Bot, c *ext.Context) error {
 		return nil
 	}
 
+	account, _ := state.State.WhatsAppAccountByTgChat(c.EffectiveChat.Id)
+
 	var (
-		waClient = state.State.WhatsAppClient
+		waClient = account.Client
 		cq       = c.CallbackQuery
 		data     = strings.Split(cq.Data, "_")
 	)
@@ -758,3 +862,510 @@ func RevokeCallbackHandler(b *gotgbot.Bot, c *ext.Context) error {
 		return err
 	}
 }
+
+func ChatSettingsHandler(b *gotgbot.Bot, c *ext.Context) error {
+	if !utils.TgUpdateIsAuthorized(b, c) {
+		return nil
+	}
+
+	if !c.EffectiveMessage.IsTopicMessage || c.EffectiveMessage.MessageThreadId == 0 {
+		_, err := utils.TgReplyTextByContext(b, c, "The command should be sent in a topic", nil)
+		return err
+	}
+
+	account, _ := state.State.WhatsAppAccountByTgChat(c.EffectiveChat.Id)
+
+	waChatId, err := database.ChatThreadGetWaFromTg(c.EffectiveChat.Id, c.EffectiveMessage.MessageThreadId)
+	if err != nil {
+		return utils.TgReplyWithErrorByContext(b, c, "Failed to find the chat pairing between this topic and a WhatsApp chat", err)
+	} else if waChatId == "" || utils.IsSystemTopic(waChatId) {
+		_, err = utils.TgReplyTextByContext(b, c, "No mapping found between current topic and a WhatsApp chat", nil)
+		return err
+	}
+
+	keyboard, err := utils.TgMakeChatSettingsKeyboard(account, waChatId)
+	if err != nil {
+		return utils.TgReplyWithErrorByContext(b, c, "Failed to get the settings of the chat", err)
+	}
+
+	_, err = utils.TgReplyTextByContext(b, c, chatSettingsText(waChatId), keyboard)
+	return err
+}
+
+func ChatSettingsCallbackHandler(b *gotgbot.Bot, c *ext.Context) error {
+	if !utils.TgUpdateIsAuthorized(b, c) {
+		return nil
+	}
+
+	var (
+		cq  = c.CallbackQuery
+		key = strings.TrimPrefix(cq.Data, "chatsettings_")
+	)
+
+	account, _ := state.State.WhatsAppAccountByTgChat(c.EffectiveChat.Id)
+
+	waChatId, err := database.ChatThreadGetWaFromTg(c.EffectiveChat.Id, c.EffectiveMessage.MessageThreadId)
+	if err != nil {
+		return utils.TgReplyWithErrorByContext(b, c, "Failed to find the chat pairing between this topic and a WhatsApp chat", err)
+	} else if waChatId == "" || utils.IsSystemTopic(waChatId) {
+		return utils.TgReplyWithErrorByContext(b, c, "Failed to change the setting",
+			fmt.Errorf("no mapping found between current topic and a WhatsApp chat"))
+	}
+
+	if err = utils.WaChangeChatSetting(account, waChatId, key); err != nil {
+		return utils.TgReplyWithErrorByContext(b, c, "Failed to change the setting", err)
+	}
+
+	keyboard, err := utils.TgMakeChatSettingsKeyboard(account, waChatId)
+	if err != nil {
+		return utils.TgReplyWithErrorByContext(b, c, "Failed to get the settings of the chat", err)
+	}
+
+	_, _, err = b.EditMessageText(chatSettingsText(waChatId), &gotgbot.EditMessageTextOpts{
+		ChatId:      c.EffectiveChat.Id,
+		MessageId:   c.EffectiveMessage.MessageId,
+		ReplyMarkup: *keyboard,
+	})
+	cq.Answer(b, &gotgbot.AnswerCallbackQueryOpts{
+		Text: "Saved",
+	})
+	return err
+}
+
+func StatusSeenCallbackHandler(b *gotgbot.Bot, c *ext.Context) error {
+	if !utils.TgUpdateIsAuthorized(b, c) {
+		return nil
+	}
+
+	cq := c.CallbackQuery
+
+	headerId, err := strconv.ParseUint(strings.TrimPrefix(cq.Data, "statusseen_"), 10, 64)
+	if err != nil {
+		return utils.TgReplyWithErrorByContext(b, c, "Invalid callback data", err)
+	}
+
+	account, _ := state.State.WhatsAppAccountByTgChat(c.EffectiveChat.Id)
+
+	seenCount, err := utils.WaMarkStatusesSeen(account, uint(headerId))
+	if err != nil {
+		return utils.TgReplyWithErrorByContext(b, c, "Failed to mark the statuses as seen", err)
+	}
+
+	_, err = cq.Answer(b, &gotgbot.AnswerCallbackQueryOpts{
+		Text: fmt.Sprintf("Marked %v statuses as seen", seenCount),
+	})
+	return err
+}
+
+func MuteChatHandler(b *gotgbot.Bot, c *ext.Context) error {
+	if !utils.TgUpdateIsAuthorized(b, c) {
+		return nil
+	}
+
+	usageString := "Usage: (Send in a topic) <code>" + html.EscapeString("/mute [duration]") + "</code>\n"
+	usageString += "Example: <code>/mute 2h30m</code> or <code>/mute 3d</code>, mutes indefinitely without a duration"
+
+	if !c.EffectiveMessage.IsTopicMessage || c.EffectiveMessage.MessageThreadId == 0 {
+		_, err := utils.TgReplyTextByContext(b, c, usageString, nil)
+		return err
+	}
+
+	var until time.Time
+	if args := c.Args(); len(args) > 1 {
+		duration, err := parseMuteDuration(args[1])
+		if err != nil || duration <= 0 {
+			_, err = utils.TgReplyTextByContext(b, c, usageString, nil)
+			return err
+		}
+		until = time.Now().Add(duration)
+	}
+
+	account, _ := state.State.WhatsAppAccountByTgChat(c.EffectiveChat.Id)
+
+	waChatId, err := database.ChatThreadGetWaFromTg(c.EffectiveChat.Id, c.EffectiveMessage.MessageThreadId)
+	if err != nil {
+		return utils.TgReplyWithErrorByContext(b, c, "Failed to find the chat pairing between this topic and a WhatsApp chat", err)
+	} else if waChatId == "" || utils.IsSystemTopic(waChatId) {
+		_, err = utils.TgReplyTextByContext(b, c, "No mapping found between current topic and a WhatsApp chat", nil)
+		return err
+	}
+
+	if err = utils.WaMuteChat(account, waChatId, until); err != nil {
+		return utils.TgReplyWithErrorByContext(b, c, "Failed to mute the chat", err)
+	}
+
+	waChatJid, _ := utils.WaParseJID(waChatId)
+	settings, _ := utils.WaGetChatSettings(account, waChatJid)
+	replyText := "<b>Muted</b> indefinitely"
+	if !until.IsZero() {
+		replyText = fmt.Sprintf("<b>Muted</b> till %s",
+			html.EscapeString(until.In(state.State.LocalLocation).Format(state.State.Config.TimeFormat)))
+	}
+	if settings.MuteMode == utils.MuteModeHold {
+		replyText += ", the messages received meanwhile will be sent as a digest"
+	}
+	if settings.MuteAllowMentions {
+		replyText += "\nMessages mentioning you will still be bridged"
+	}
+
+	_, err = utils.TgReplyTextByContext(b, c, replyText, nil)
+	return err
+}
+
+func UnmuteChatHandler(b *gotgbot.Bot, c *ext.Context) error {
+	if !utils.TgUpdateIsAuthorized(b, c) {
+		return nil
+	}
+
+	if !c.EffectiveMessage.IsTopicMessage || c.EffectiveMessage.MessageThreadId == 0 {
+		_, err := utils.TgReplyTextByContext(b, c, "The command should be sent in a topic", nil)
+		return err
+	}
+
+	account, _ := state.State.WhatsAppAccountByTgChat(c.EffectiveChat.Id)
+
+	waChatId, err := database.ChatThreadGetWaFromTg(c.EffectiveChat.Id, c.EffectiveMessage.MessageThreadId)
+	if err != nil {
+		return utils.TgReplyWithErrorByContext(b, c, "Failed to find the chat pairing between this topic and a WhatsApp chat", err)
+	} else if waChatId == "" || utils.IsSystemTopic(waChatId) {
+		_, err = utils.TgReplyTextByContext(b, c, "No mapping found between current topic and a WhatsApp chat", nil)
+		return err
+	}
+
+	if err = utils.WaUnmuteChat(account, waChatId); err != nil {
+		return utils.TgReplyWithErrorByContext(b, c, "Failed to unmute the chat", err)
+	}
+
+	_, err = utils.TgReplyTextByContext(b, c, "<b>Unmuted</b>", nil)
+	return err
+}
+
+func AlertsHandler(b *gotgbot.Bot, c *ext.Context) error {
+	if !utils.TgUpdateIsAuthorized(b, c) {
+		return nil
+	}
+
+	usageString := "Usage:\n"
+	usageString += "<code>" + html.EscapeString("/alerts add <keyword|/regex/> [chats=<id>,...] [senders=<number>,...]") + "</code>\n"
+	usageString += "<code>/alerts list</code>\n"
+	usageString += "<code>" + html.EscapeString("/alerts remove <rule_id>") + "</code>\n"
+	usageString += "Example: <code>/alerts add /(?i)urgent|asap/ chats=1234567890-1234567890</code>"
+
+	args := c.Args()
+	if len(args) < 2 {
+		_, err := utils.TgReplyTextByContext(b, c, usageString, nil)
+		return err
+	}
+
+	switch args[1] {
+	case "add":
+		var (
+			rule         database.AlertRule
+			patternWords []string
+		)
+		for _, arg := range args[2:] {
+			if strings.HasPrefix(arg, "chats=") {
+				rule.Chats = parseAlertRuleUsers(strings.TrimPrefix(arg, "chats="))
+			} else if strings.HasPrefix(arg, "senders=") {
+				rule.Senders = parseAlertRuleUsers(strings.TrimPrefix(arg, "senders="))
+			} else {
+				patternWords = append(patternWords, arg)
+			}
+		}
+		rule.Pattern = strings.Join(patternWords, " ")
+		if len(rule.Pattern) > 2 && strings.HasPrefix(rule.Pattern, "/") && strings.HasSuffix(rule.Pattern, "/") {
+			rule.Pattern = rule.Pattern[1 : len(rule.Pattern)-1]
+			rule.IsRegex = true
+			if _, err := regexp.Compile(rule.Pattern); err != nil {
+				return utils.TgReplyWithErrorByContext(b, c, "Invalid regex", err)
+			}
+		}
+		if rule.Pattern == "" {
+			_, err := utils.TgReplyTextByContext(b, c, usageString, nil)
+			return err
+		}
+
+		if err := database.AlertRuleAdd(&rule); err != nil {
+			return utils.TgReplyWithErrorByContext(b, c, "Failed to add the alert rule", err)
+		}
+		_, err := utils.TgReplyTextByContext(b, c, fmt.Sprintf("Added the alert rule <code>%v</code>", rule.ID), nil)
+		return err
+
+	case "list":
+		rules, err := database.AlertRuleGetAll()
+		if err != nil {
+			return utils.TgReplyWithErrorByContext(b, c, "Failed to get the alert rules", err)
+		} else if len(rules) == 0 {
+			_, err = utils.TgReplyTextByContext(b, c, "No alert rules have been added", nil)
+			return err
+		}
+
+		outputString := "<b>Alert rules:</b>\n"
+		for _, rule := range rules {
+			pattern := html.EscapeString(rule.Pattern)
+			if rule.IsRegex {
+				pattern = "/" + pattern + "/"
+			}
+			outputString += fmt.Sprintf(" • <code>%v</code>: <code>%s</code>", rule.ID, pattern)
+			if rule.Chats != "" {
+				outputString += fmt.Sprintf(" in <code>%s</code>", html.EscapeString(rule.Chats))
+			}
+			if rule.Senders != "" {
+				outputString += fmt.Sprintf(" from <code>%s</code>", html.EscapeString(rule.Senders))
+			}
+			outputString += "\n"
+
+			if len(outputString) >= 1800 {
+				utils.TgReplyTextByContext(b, c, outputString, nil)
+				time.Sleep(500 * time.Millisecond)
+				outputString = ""
+			}
+		}
+
+		if len(outputString) > 0 {
+			_, err = utils.TgReplyTextByContext(b, c, outputString, nil)
+			return err
+		}
+		return nil
+
+	case "remove":
+		if len(args) < 3 {
+			_, err := utils.TgReplyTextByContext(b, c, usageString, nil)
+			return err
+		}
+		ruleId, err := strconv.ParseUint(args[2], 10, 64)
+		if err != nil {
+			_, err = utils.TgReplyTextByContext(b, c, usageString, nil)
+			return err
+		}
+
+		found, err := database.AlertRuleDelete(uint(ruleId))
+		if err != nil {
+			return utils.TgReplyWithErrorByContext(b, c, "Failed to remove the alert rule", err)
+		} else if !found {
+			_, err = utils.TgReplyTextByContext(b, c, "No alert rule found with that ID", nil)
+			return err
+		}
+		_, err = utils.TgReplyTextByContext(b, c, "Removed the alert rule", nil)
+		return err
+	}
+
+	_, err := utils.TgReplyTextByContext(b, c, usageString, nil)
+	return err
+}
+
+func RecreateSystemTopicHandler(b *gotgbot.Bot, c *ext.Context) error {
+	if !utils.TgUpdateIsAuthorized(b, c) {
+		return nil
+	}
+
+	var topicNames []string
+	for name := range utils.SystemTopics {
+		topicNames = append(topicNames, name)
+	}
+	sort.Strings(topicNames)
+
+	usageString := "Usage: <code>" + html.EscapeString("/recreatetopic <topic>") + "</code>\n"
+	usageString += "Topics: <code>" + strings.Join(topicNames, "</code>, <code>") + "</code>"
+
+	args := c.Args()
+	if len(args) < 2 {
+		_, err := utils.TgReplyTextByContext(b, c, usageString, nil)
+		return err
+	}
+	key, found := utils.SystemTopics[strings.ToLower(args[1])]
+	if !found {
+		_, err := utils.TgReplyTextByContext(b, c, usageString, nil)
+		return err
+	}
+
+	account, isBridged := state.State.WhatsAppAccountByTgChat(c.EffectiveChat.Id)
+	if !isBridged {
+		_, err := utils.TgReplyTextByContext(b, c, "This command has to be used in a chat which WhatsApp is bridged into", nil)
+		return err
+	}
+
+	threadId, err := utils.TgRemakeSystemThread(account, key, c.EffectiveChat.Id)
+	if err != nil {
+		return utils.TgReplyWithErrorByContext(b, c, "Failed to create the topic", err)
+	}
+
+	return utils.TgSendTextById(b, c.EffectiveChat.Id, threadId, "This topic has been created to replace the deleted one")
+}
+
+func PostStatusHandler(b *gotgbot.Bot, c *ext.Context) error {
+	if !utils.TgUpdateIsAuthorized(b, c) {
+		return nil
+	}
+
+	var (
+		cfg          = state.State.Config
+		msgToPost    = c.EffectiveMessage.ReplyToMessage
+		usageString  = "Usage: (Reply to a text, photo or video) <code>" + html.EscapeString("/poststatus [#RRGGBB] [font]") + "</code>\n"
+		colorAndFont = c.Args()[1:]
+	)
+	usageString += "Example: <code>/poststatus #7E57C2 serif</code>, the colour and font only apply to text statuses"
+
+	if msgToPost == nil || msgToPost.ForumTopicCreated != nil {
+		_, err := utils.TgReplyTextByContext(b, c, usageString, nil)
+		return err
+	}
+
+	account, _ := state.State.WhatsAppAccountByTgChat(c.EffectiveChat.Id)
+
+	if msgToPost.Photo != nil || msgToPost.Video != nil {
+		return utils.TgSendToWhatsApp(b, c, account, msgToPost, nil, waTypes.StatusBroadcastJID, "", "", false)
+	} else if msgToPost.Text == "" {
+		_, err := utils.TgReplyTextByContext(b, c, "Only text, photos and videos can be posted as status", nil)
+		return err
+	}
+
+	backgroundColor, font := cfg.WhatsApp.StatusPosting.BackgroundColor, cfg.WhatsApp.StatusPosting.Font
+	for _, arg := range colorAndFont {
+		if strings.HasPrefix(arg, "#") {
+			backgroundColor = arg
+		} else {
+			font = arg
+		}
+	}
+	backgroundArgb, err := utils.WaParseStatusColor(backgroundColor)
+	if err != nil {
+		return utils.TgReplyWithErrorByContext(b, c, "Invalid background colour", err)
+	}
+	fontType, err := utils.WaParseStatusFont(font)
+	if err != nil {
+		return utils.TgReplyWithErrorByContext(b, c, "Invalid font", err)
+	}
+
+	sentMsg, err := utils.WaPostTextStatus(account, msgToPost.Text, backgroundArgb, fontType)
+	if err != nil {
+		return utils.TgReplyWithErrorByContext(b, c, "Failed to post the status", err)
+	}
+
+	err = database.MsgIdAddNewPair(account.Name, sentMsg.ID, account.Client.Store.ID.String(), waTypes.StatusBroadcastJID.String(),
+		msgToPost.Chat.Id, msgToPost.MessageId, msgToPost.MessageThreadId)
+	if err != nil {
+		return utils.TgReplyWithErrorByContext(b, c, "Failed to add to database", err)
+	}
+
+	_, err = utils.TgReplyTextByContext(b, c, "Posted the status",
+		utils.TgMakeRevokeKeyboard(sentMsg.ID, waTypes.StatusBroadcastJID.String(), false))
+	return err
+}
+
+func DisappearingHandler(b *gotgbot.Bot, c *ext.Context) error {
+	if !utils.TgUpdateIsAuthorized(b, c) {
+		return nil
+	}
+
+	usageString := "Usage: <code>" + html.EscapeString("/disappearing [off|24h|7d|90d]") + "</code>"
+
+	account, _ := state.State.WhatsAppAccountByTgChat(c.EffectiveChat.Id)
+
+	waChatId, err := database.ChatThreadGetWaFromTg(c.EffectiveChat.Id, c.EffectiveMessage.MessageThreadId)
+	if err != nil {
+		return utils.TgReplyWithErrorByContext(b, c, "Failed to find the chat pairing between this topic and a WhatsApp chat", err)
+	} else if waChatId == "" || utils.IsSystemTopic(waChatId) {
+		_, err = utils.TgReplyTextByContext(b, c, "No mapping found between current topic and a WhatsApp chat", nil)
+		return err
+	}
+	waChatJid, _ := utils.WaParseJID(waChatId)
+
+	args := c.Args()
+	if len(args) < 2 {
+		seconds, err := utils.WaGetDisappearingTimer(account, waChatJid)
+		if err != nil {
+			return utils.TgReplyWithErrorByContext(b, c, "Failed to get the disappearing messages timer", err)
+		}
+		_, err = utils.TgReplyTextByContext(b, c,
+			fmt.Sprintf("Disappearing messages: <b>%s</b>\n%s", utils.WaFormatDisappearingTimer(seconds), usageString), nil)
+		return err
+	}
+
+	timer, found := utils.DisappearingTimers[strings.ToLower(args[1])]
+	if !found {
+		_, err = utils.TgReplyTextByContext(b, c, usageString, nil)
+		return err
+	}
+
+	if err = account.Client.SetDisappearingTimer(waChatJid, timer); err != nil {
+		return utils.TgReplyWithErrorByContext(b, c, "Failed to set the disappearing messages timer", err)
+	}
+	seconds := uint32(timer.Seconds())
+	if err = utils.WaUpdateDisappearingTimer(account, waChatJid, seconds, false); err != nil {
+		return utils.TgReplyWithErrorByContext(b, c, "Failed to save the disappearing messages timer", err)
+	}
+
+	_, err = utils.TgReplyTextByContext(b, c,
+		fmt.Sprintf("Disappearing messages: <b>%s</b>", utils.WaFormatDisappearingTimer(seconds)), nil)
+	return err
+}
+
+func StealStickerHandler(b *gotgbot.Bot, c *ext.Context) error {
+	if !utils.TgUpdateIsAuthorized(b, c) {
+		return nil
+	}
+
+	msgToSteal := c.EffectiveMessage.ReplyToMessage
+	if msgToSteal == nil || msgToSteal.Sticker == nil {
+		_, err := utils.TgReplyTextByContext(b, c, "Usage: (Reply to a sticker) <code>/stealsticker</code>", nil)
+		return err
+	}
+
+	// Stickers sent by the bot were bridged from WhatsApp, so they go to the Telegram set
+	if msgToSteal.From != nil && msgToSteal.From.Id == b.Id {
+		setName, err := utils.TgAddStickerToBotSet(b, msgToSteal.Sticker)
+		if err != nil {
+			return utils.TgReplyWithErrorByContext(b, c, "Failed to add the sticker to the set (the owner has to have started the bot)", err)
+		}
+		_, err = utils.TgReplyTextByContext(b, c,
+			fmt.Sprintf("Added the sticker to <a href=\"https://t.me/addstickers/%s\">this set</a>", setName), nil)
+		return err
+	}
+
+	// WhatsApp has no sticker sets for bots to add to, so the sticker is sent to your own chat,
+	// tagged with the pack name and author from sticker_metadata, to be added to the favourites
+	account, _ := state.State.WhatsAppAccountByTgChat(c.EffectiveChat.Id)
+	msgToSend, err := utils.TgMakeWhatsAppSticker(b, account, msgToSteal.Sticker, c.UpdateId)
+	if err != nil {
+		return utils.TgReplyWithErrorByContext(b, c, "Failed to convert the sticker for WhatsApp", err)
+	}
+	if _, err = account.Client.SendMessage(context.Background(), account.Client.Store.ID.ToNonAD(), msgToSend); err != nil {
+		return utils.TgReplyWithErrorByContext(b, c, "Failed to send the sticker to WhatsApp", err)
+	}
+
+	_, err = utils.TgReplyTextByContext(b, c, "Sent the sticker to your own WhatsApp chat, from where it can be added to your favourites", nil)
+	return err
+}
+
+// parseAlertRuleUsers turns a comma separated list of JIDs or phone numbers into
+// the users of the JIDs, which is how the chats and senders of alert rules are stored
+func parseAlertRuleUsers(s string) string {
+	var users []string
+	for _, user := range strings.Split(s, ",") {
+		user = strings.TrimPrefix(strings.TrimSpace(user), "+")
+		if i := strings.Index(user, "@"); i >= 0 {
+			user = user[:i]
+		}
+		if user != "" {
+			users = append(users, user)
+		}
+	}
+	return strings.Join(users, ",")
+}
+
+// parseMuteDuration parses durations like time.ParseDuration, along with days and weeks
+func parseMuteDuration(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if strings.HasSuffix(s, suffix) {
+			count, err := strconv.Atoi(strings.TrimSuffix(s, suffix))
+			return time.Duration(count) * unit, err
+		}
+	}
+	return time.ParseDuration(s)
+}
+
+func chatSettingsText(waChatId string) string {
+	return fmt.Sprintf("<b>Settings of</b> <code>%s</code>\n"+
+		"The ones marked with * override the defaults from the config file", html.EscapeString(waChatId))
+}
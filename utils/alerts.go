@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"watgbridge/database"
+	"watgbridge/state"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
+)
+
+// AlertRuleMatches reports whether the text, sent by the sender in the chat, matches the rule
+func AlertRuleMatches(rule database.AlertRule, chat, sender types.JID, text string) bool {
+	if rule.Chats != "" && !slices.Contains(strings.Split(rule.Chats, ","), chat.User) {
+		return false
+	}
+	if rule.Senders != "" && !slices.Contains(strings.Split(rule.Senders, ","), sender.User) {
+		return false
+	}
+
+	if rule.IsRegex {
+		re, err := regexp.Compile(rule.Pattern)
+		return err == nil && re.MatchString(text)
+	}
+	return strings.Contains(strings.ToLower(text), strings.ToLower(rule.Pattern))
+}
+
+// TgGetMessageLink returns the link to a message in a supergroup, which opens it
+// in its topic if there is one
+func TgGetMessageLink(chatId, threadId, msgId int64) string {
+	link := fmt.Sprintf("https://t.me/c/%s/%v", strings.TrimPrefix(strconv.FormatInt(chatId, 10), "-100"), msgId)
+	if threadId != 0 {
+		link += fmt.Sprintf("?thread=%v", threadId)
+	}
+	return link
+}
+
+// TgSendAlerts copies the message to the alerts topic if it matches any of the alert
+// rules, linking to where it was bridged or else to the topic of its chat. It returns
+// the ID of the alert sent, or 0 if none was
+func TgSendAlerts(account *state.WhatsAppAccount, v *events.Message, targetChatId int64) int64 {
+	var (
+		logger = state.State.Logger
+		tgBot  = state.State.TelegramBot
+		sender = v.Info.MessageSource.Sender.ToNonAD()
+	)
+	defer logger.Sync()
+
+	text := WaGetMessageText(v.Message)
+	if text == "" {
+		return 0
+	}
+
+	rules, err := database.AlertRuleGetAll()
+	if err != nil {
+		logger.Error("failed to get the alert rules",
+			zap.Error(err),
+		)
+		return 0
+	}
+
+	var matchedPatterns []string
+	for _, rule := range rules {
+		if AlertRuleMatches(rule, v.Info.Chat, sender, text) {
+			matchedPatterns = append(matchedPatterns, "<code>"+html.EscapeString(rule.Pattern)+"</code>")
+		}
+	}
+	if len(matchedPatterns) == 0 {
+		return 0
+	}
+
+	alertText := "<b>#Alerts</b>\n"
+	alertText += fmt.Sprintf("Matched: %s\n", strings.Join(matchedPatterns, ", "))
+	if v.Info.IsGroup {
+		alertText += fmt.Sprintf("Chat: <b>%s</b>\n", html.EscapeString(WaGetGroupName(account, v.Info.Chat)))
+	}
+	alertText += fmt.Sprintf("From: <b>%s</b>\n\n", html.EscapeString(WaGetContactName(sender)))
+	if len(text) > 1000 {
+		alertText += html.EscapeString(SubString(text, 0, 1000)) + "..."
+	} else {
+		alertText += html.EscapeString(text)
+	}
+
+	tgChatId, tgThreadId, tgMsgId, err := database.MsgIdGetTgFromWa(account.Name, v.Info.ID, v.Info.Chat.String())
+	if err == nil && tgChatId == targetChatId {
+		alertText += fmt.Sprintf("\n\n<a href=\"%s\">Go to message</a>", TgGetMessageLink(tgChatId, tgThreadId, tgMsgId))
+	} else {
+		// Not bridged as it is muted or held for a digest
+		chatThreadKey := v.Info.Chat.ToNonAD().String()
+		if v.Info.IsIncomingBroadcast() {
+			chatThreadKey = sender.String()
+		}
+		if tgThreadId, found, err := database.ChatThreadGetTgFromWa(account.Name, chatThreadKey, targetChatId); err == nil && found {
+			alertText += fmt.Sprintf("\n\n<a href=\"%s\">Go to chat</a>", TgGetMessageLink(targetChatId, 0, tgThreadId))
+		}
+	}
+
+	threadId, err := TgGetOrMakeSystemThread(account, SystemTopicAlerts, targetChatId)
+	if err != nil {
+		TgSendBridgeError(account, targetChatId, "failed to create/find thread id for alerts", err)
+		return 0
+	}
+
+	sentMsg, err := tgBot.SendMessage(targetChatId, alertText, &gotgbot.SendMessageOpts{
+		MessageThreadId:       threadId,
+		DisableWebPagePreview: true,
+	})
+	if err != nil {
+		logger.Error("failed to send alert",
+			zap.String("event_id", v.Info.ID),
+			zap.Error(err),
+		)
+		return 0
+	}
+	return sentMsg.MessageId
+}
@@ -0,0 +1,316 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+
+	"watgbridge/database"
+	"watgbridge/state"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"go.mau.fi/whatsmeow/types"
+	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
+)
+
+const (
+	HeaderStyleFull    = "full"
+	HeaderStyleCompact = "compact"
+
+	ReadReceiptsNever    = "never"
+	ReadReceiptsOnBridge = "on_bridge"
+	ReadReceiptsOnReply  = "on_reply"
+
+	MuteModeDrop = "drop"
+	MuteModeHold = "hold"
+)
+
+// WaChatSettings are the bridging settings in effect for a WhatsApp chat, taken
+// from the chat's settings in the database or else from the config file
+type WaChatSettings struct {
+	Muted             bool
+	MutedUntil        time.Time // Zero if the mute is indefinite
+	MuteMode          string
+	MuteAllowMentions bool
+	StatusMuted       bool
+	SkipDocuments     bool
+	SkipImages        bool
+	SkipGIFs          bool
+	SkipVideos        bool
+	SkipVoiceNotes    bool
+	SkipAudios        bool
+	SkipStickers      bool
+	SkipContacts      bool
+	SkipLocations     bool
+	HeaderStyle       string
+	ReadReceipts      string
+	MentionAlerts     bool
+	Digest            bool
+}
+
+type chatSettingToggle struct {
+	key      string
+	field    string
+	label    string
+	inverted bool // The button shows whether the thing is bridged, while the setting is about skipping it
+	stored   func(*database.ChatSettings) *bool
+	value    func(*WaChatSettings) *bool
+}
+
+var chatSettingToggles = []chatSettingToggle{
+	{"mute", "Muted", "Muted", false,
+		func(s *database.ChatSettings) *bool { return s.Muted }, func(s *WaChatSettings) *bool { return &s.Muted }},
+	{"mmen", "MuteAllowMentions", "Mentions when muted", false,
+		func(s *database.ChatSettings) *bool { return s.MuteAllowMentions }, func(s *WaChatSettings) *bool { return &s.MuteAllowMentions }},
+	{"smute", "StatusMuted", "Status muted", false,
+		func(s *database.ChatSettings) *bool { return s.StatusMuted }, func(s *WaChatSettings) *bool { return &s.StatusMuted }},
+	{"img", "SkipImages", "Images", true,
+		func(s *database.ChatSettings) *bool { return s.SkipImages }, func(s *WaChatSettings) *bool { return &s.SkipImages }},
+	{"gif", "SkipGIFs", "GIFs", true,
+		func(s *database.ChatSettings) *bool { return s.SkipGIFs }, func(s *WaChatSettings) *bool { return &s.SkipGIFs }},
+	{"vid", "SkipVideos", "Videos", true,
+		func(s *database.ChatSettings) *bool { return s.SkipVideos }, func(s *WaChatSettings) *bool { return &s.SkipVideos }},
+	{"ptt", "SkipVoiceNotes", "Voice notes", true,
+		func(s *database.ChatSettings) *bool { return s.SkipVoiceNotes }, func(s *WaChatSettings) *bool { return &s.SkipVoiceNotes }},
+	{"aud", "SkipAudios", "Audios", true,
+		func(s *database.ChatSettings) *bool { return s.SkipAudios }, func(s *WaChatSettings) *bool { return &s.SkipAudios }},
+	{"doc", "SkipDocuments", "Documents", true,
+		func(s *database.ChatSettings) *bool { return s.SkipDocuments }, func(s *WaChatSettings) *bool { return &s.SkipDocuments }},
+	{"stk", "SkipStickers", "Stickers", true,
+		func(s *database.ChatSettings) *bool { return s.SkipStickers }, func(s *WaChatSettings) *bool { return &s.SkipStickers }},
+	{"cnt", "SkipContacts", "Contacts", true,
+		func(s *database.ChatSettings) *bool { return s.SkipContacts }, func(s *WaChatSettings) *bool { return &s.SkipContacts }},
+	{"loc", "SkipLocations", "Locations", true,
+		func(s *database.ChatSettings) *bool { return s.SkipLocations }, func(s *WaChatSettings) *bool { return &s.SkipLocations }},
+	{"men", "MentionAlerts", "Mention alerts", false,
+		func(s *database.ChatSettings) *bool { return s.MentionAlerts }, func(s *WaChatSettings) *bool { return &s.MentionAlerts }},
+	{"dig", "Digest", "Digest mode", false,
+		func(s *database.ChatSettings) *bool { return s.Digest }, func(s *WaChatSettings) *bool { return &s.Digest }},
+}
+
+type chatSettingChoice struct {
+	key     string
+	field   string
+	label   string
+	options []string
+	stored  func(*database.ChatSettings) *string
+	value   func(*WaChatSettings) *string
+}
+
+var chatSettingChoices = []chatSettingChoice{
+	{"mm", "MuteMode", "When muted", []string{MuteModeDrop, MuteModeHold},
+		func(s *database.ChatSettings) *string { return s.MuteMode }, func(s *WaChatSettings) *string { return &s.MuteMode }},
+	{"hdr", "HeaderStyle", "Header", []string{HeaderStyleFull, HeaderStyleCompact},
+		func(s *database.ChatSettings) *string { return s.HeaderStyle }, func(s *WaChatSettings) *string { return &s.HeaderStyle }},
+	{"rr", "ReadReceipts", "Read receipts", []string{ReadReceiptsNever, ReadReceiptsOnBridge, ReadReceiptsOnReply},
+		func(s *database.ChatSettings) *string { return s.ReadReceipts }, func(s *WaChatSettings) *string { return &s.ReadReceipts }},
+}
+
+// WaGetChatSettings returns the settings in effect for the chat. The settings from the
+// config file are returned along with the error if the database could not be read.
+func WaGetChatSettings(account *state.WhatsAppAccount, chat types.JID) (WaChatSettings, error) {
+	settings, _, err := waGetChatSettings(account, chat.ToNonAD().String())
+	return settings, err
+}
+
+func waGetChatSettings(account *state.WhatsAppAccount, waChatId string) (WaChatSettings, database.ChatSettings, error) {
+	var (
+		cfg     = state.State.Config
+		chat, _ = WaParseJID(waChatId)
+	)
+
+	settings := WaChatSettings{
+		Muted:             slices.Contains(account.IgnoreChats, chat.User),
+		MuteMode:          cfg.WhatsApp.MuteMode,
+		MuteAllowMentions: cfg.WhatsApp.MuteAllowMentions,
+		StatusMuted:       slices.Contains(account.StatusIgnoredChats, chat.User),
+		SkipDocuments:     cfg.WhatsApp.SkipDocuments,
+		SkipImages:        cfg.WhatsApp.SkipImages,
+		SkipGIFs:          cfg.WhatsApp.SkipGIFs,
+		SkipVideos:        cfg.WhatsApp.SkipVideos,
+		SkipVoiceNotes:    cfg.WhatsApp.SkipVoiceNotes,
+		SkipAudios:        cfg.WhatsApp.SkipAudios,
+		SkipStickers:      cfg.WhatsApp.SkipStickers,
+		SkipContacts:      cfg.WhatsApp.SkipContacts,
+		SkipLocations:     cfg.WhatsApp.SkipLocations,
+		HeaderStyle:       HeaderStyleFull,
+		ReadReceipts:      cfg.WhatsApp.ReadReceipts,
+		MentionAlerts:     cfg.WhatsApp.MentionAlerts,
+	}
+	if cfg.WhatsApp.SkipChatDetails {
+		settings.HeaderStyle = HeaderStyleCompact
+	}
+
+	stored, err := database.ChatSettingsGet(account.Name, waChatId)
+	if err != nil {
+		return settings, stored, err
+	}
+
+	for _, toggle := range chatSettingToggles {
+		if value := toggle.stored(&stored); value != nil {
+			*toggle.value(&settings) = *value
+		}
+	}
+	for _, choice := range chatSettingChoices {
+		if value := choice.stored(&stored); value != nil {
+			*choice.value(&settings) = *value
+		}
+	}
+
+	if stored.MutedUntil != nil {
+		settings.MutedUntil = *stored.MutedUntil
+		// The mute might not have been ended by the scheduler yet
+		if time.Now().After(settings.MutedUntil) {
+			settings.Muted = false
+		}
+	}
+
+	return settings, stored, nil
+}
+
+// WaChangeChatSetting flips the toggle or moves to the next option of the setting
+// with the given key, "reset" dropping all the settings stored for the chat
+func WaChangeChatSetting(account *state.WhatsAppAccount, waChatId, key string) error {
+	if key == "reset" {
+		return database.ChatSettingsReset(account.Name, waChatId)
+	}
+
+	settings, _, err := waGetChatSettings(account, waChatId)
+	if err != nil {
+		return err
+	}
+
+	if key == "mute" {
+		if settings.Muted {
+			return WaUnmuteChat(account, waChatId)
+		}
+		return WaMuteChat(account, waChatId, time.Time{})
+	}
+
+	if key == "dig" && settings.Digest {
+		// The messages collected so far are not left waiting for the next interval
+		if err = database.ChatSettingsSet(account.Name, waChatId, "Digest", false); err != nil {
+			return err
+		}
+		return TgSendHeldMessagesDigest(account, waChatId, digestTitleInterval)
+	}
+
+	for _, toggle := range chatSettingToggles {
+		if toggle.key == key {
+			return database.ChatSettingsSet(account.Name, waChatId, toggle.field, !*toggle.value(&settings))
+		}
+	}
+	for _, choice := range chatSettingChoices {
+		if choice.key == key {
+			current := slices.Index(choice.options, *choice.value(&settings))
+			next := choice.options[(current+1)%len(choice.options)]
+			return database.ChatSettingsSet(account.Name, waChatId, choice.field, next)
+		}
+	}
+
+	return fmt.Errorf("unknown chat setting '%s'", key)
+}
+
+// WaMuteChat mutes the chat till the given time, or indefinitely if it is zero
+func WaMuteChat(account *state.WhatsAppAccount, waChatId string, until time.Time) error {
+	var mutedUntil *time.Time
+	if !until.IsZero() {
+		until = until.UTC()
+		mutedUntil = &until
+	}
+
+	if err := database.ChatSettingsSet(account.Name, waChatId, "MutedUntil", mutedUntil); err != nil {
+		return err
+	}
+	return database.ChatSettingsSet(account.Name, waChatId, "Muted", true)
+}
+
+// WaUnmuteChat unmutes the chat and sends the digest of the messages held meanwhile
+func WaUnmuteChat(account *state.WhatsAppAccount, waChatId string) error {
+	if err := database.ChatSettingsSet(account.Name, waChatId, "MutedUntil", nil); err != nil {
+		return err
+	}
+	if err := database.ChatSettingsSet(account.Name, waChatId, "Muted", false); err != nil {
+		return err
+	}
+	return TgSendHeldMessagesDigest(account, waChatId, digestTitleMuted)
+}
+
+// TgMakeChatSettingsKeyboard builds the keyboard for the /chatsettings command, the
+// settings changed from the defaults being marked with a *
+func TgMakeChatSettingsKeyboard(account *state.WhatsAppAccount, waChatId string) (*gotgbot.InlineKeyboardMarkup, error) {
+	settings, stored, err := waGetChatSettings(account, waChatId)
+	if err != nil {
+		return nil, err
+	}
+
+	var buttons []gotgbot.InlineKeyboardButton
+	for _, toggle := range chatSettingToggles {
+		value := *toggle.value(&settings)
+		if toggle.inverted {
+			value = !value
+		}
+		text := toggle.label + ": off"
+		if value {
+			text = toggle.label + ": on"
+		}
+		if toggle.stored(&stored) != nil {
+			text += " *"
+		}
+		buttons = append(buttons, gotgbot.InlineKeyboardButton{
+			Text:         text,
+			CallbackData: "chatsettings_" + toggle.key,
+		})
+	}
+	for _, choice := range chatSettingChoices {
+		text := fmt.Sprintf("%s: %s", choice.label, *choice.value(&settings))
+		if choice.stored(&stored) != nil {
+			text += " *"
+		}
+		buttons = append(buttons, gotgbot.InlineKeyboardButton{
+			Text:         text,
+			CallbackData: "chatsettings_" + choice.key,
+		})
+	}
+
+	var keyboard [][]gotgbot.InlineKeyboardButton
+	for i := 0; i < len(buttons); i += 2 {
+		if i+1 < len(buttons) {
+			keyboard = append(keyboard, buttons[i:i+2])
+		} else {
+			keyboard = append(keyboard, buttons[i:])
+		}
+	}
+	keyboard = append(keyboard, []gotgbot.InlineKeyboardButton{{
+		Text:         "Reset to defaults",
+		CallbackData: "chatsettings_reset",
+	}})
+
+	return &gotgbot.InlineKeyboardMarkup{InlineKeyboard: keyboard}, nil
+}
+
+// WaMarkReadOnReply sends a read receipt for a message which was replied to from
+// Telegram, if the read receipts of its chat are set to be sent on reply
+func WaMarkReadOnReply(account *state.WhatsAppAccount, chat types.JID, participantId, msgId string) {
+	logger := state.State.Logger
+	defer logger.Sync()
+
+	settings, err := WaGetChatSettings(account, chat)
+	if err != nil || settings.ReadReceipts != ReadReceiptsOnReply {
+		return
+	}
+
+	sender := types.EmptyJID
+	if chat.Server == types.GroupServer {
+		sender, _ = WaParseJID(participantId)
+	}
+
+	err = account.Client.MarkRead([]types.MessageID{msgId}, time.Now(), chat, sender)
+	if err != nil {
+		logger.Warn("failed to mark the replied to message as read",
+			zap.String("msg_id", msgId),
+			zap.String("chat_jid", chat.String()),
+			zap.Error(err),
+		)
+	}
+}
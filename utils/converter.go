@@ -0,0 +1,190 @@
+package utils
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"os/exec"
+
+	"watgbridge/state"
+
+	"go.uber.org/zap"
+)
+
+// ErrConversionUnsupported is returned by the media converter for the conversions
+// which it cannot do, like those of videos when ffmpeg is not installed
+var ErrConversionUnsupported = errors.New("conversion is not supported without ffmpeg")
+
+// MediaConverter converts media between the formats used by WhatsApp and Telegram
+type MediaConverter interface {
+	// StickerToWebp converts a video sticker or a static image into a webp fitted into
+	// 512x512, padded with transparency
+	StickerToWebp(inputData []byte) ([]byte, error)
+	// AnimatedWebpToGif converts an animated WhatsApp sticker into a GIF
+	AnimatedWebpToGif(inputData []byte) ([]byte, error)
+	// AnimatedWebpToWebm converts an animated WhatsApp sticker into a Telegram video sticker,
+	// a VP9 WebM with alpha whose longer side is 512px, at most 3 seconds long and under 256 KB
+	AnimatedWebpToWebm(inputData []byte) ([]byte, error)
+	// AudioToVoice converts audio into the mono ogg/opus WhatsApp expects of voice notes,
+	// returning its duration in seconds and its waveform
+	AudioToVoice(inputData []byte) ([]byte, uint32, []byte, error)
+	// AudioToM4a converts audio into AAC in an m4a container
+	AudioToM4a(inputData []byte) ([]byte, error)
+	// VideoToVideoNote crops a video into the square H.264 mp4 used for round videos,
+	// keeping it within the length, duration and size limits of video notes
+	VideoToVideoNote(inputData []byte) ([]byte, error)
+	// ProbeVideo reads the dimensions and duration of a video, and takes its first frame
+	ProbeVideo(inputData []byte) (*VideoInfo, error)
+	// PdfFirstPage renders the first page of a PDF as a PNG image
+	PdfFirstPage(inputData []byte) ([]byte, error)
+}
+
+// VideoInfo is what is known of a video from its headers and its first frame
+type VideoInfo struct {
+	Width   int
+	Height  int
+	Seconds uint32
+	// FirstFrame is the first frame as a PNG image, which is empty if it could not be taken
+	FirstFrame []byte
+}
+
+const (
+	// VideoNoteMaxLength is the largest width and height of video notes
+	VideoNoteMaxLength = 640
+	// VideoNoteMaxSeconds is the longest video note which can be sent on either side
+	VideoNoteMaxSeconds = 60
+	// VideoNoteSizeLimit is the size beyond which WhatsApp does not accept video notes
+	VideoNoteSizeLimit = 16 * 1024 * 1024
+)
+
+// Converter is the media converter used by the bridge, set up by SetupMediaConverter
+var Converter MediaConverter = &GoConverter{}
+
+// SetupMediaConverter uses ffmpeg for media conversion if it can be found, and
+// otherwise the limited converter written in Go
+func SetupMediaConverter() {
+	var (
+		cfg    = state.State.Config
+		logger = state.State.Logger
+	)
+	defer logger.Sync()
+
+	if cfg.FfmpegExecutable != "" {
+		if ffmpegPath, err := exec.LookPath(cfg.FfmpegExecutable); err == nil {
+			Converter = &FfmpegConverter{FfmpegExecutable: ffmpegPath}
+			return
+		}
+	}
+
+	logger.Warn("ffmpeg was not found, only static stickers and images will be converted",
+		zap.String("ffmpeg_executable", cfg.FfmpegExecutable),
+	)
+	Converter = &GoConverter{}
+}
+
+// ConvertVoiceForWhatsApp converts a voice note into ogg/opus, returning it with its
+// duration and waveform. It is returned as it was if it cannot be converted.
+func ConvertVoiceForWhatsApp(voiceData []byte, seconds uint32) ([]byte, uint32, []byte) {
+	logger := state.State.Logger
+	defer logger.Sync()
+
+	convertedData, convertedSeconds, waveform, err := Converter.AudioToVoice(voiceData)
+	if err != nil {
+		if !errors.Is(err, ErrConversionUnsupported) {
+			logger.Warn("failed to convert voice note, sending it as it is",
+				zap.Error(err),
+			)
+		}
+		return voiceData, seconds, nil
+	}
+
+	if convertedSeconds == 0 {
+		convertedSeconds = seconds
+	}
+	return convertedData, convertedSeconds, waveform
+}
+
+// ConvertAudioForWhatsApp converts audio which is not already AAC into m4a, returning it
+// with its MIME type. It is returned as it was if it cannot be converted.
+func ConvertAudioForWhatsApp(audioData []byte, mimeType string) ([]byte, string) {
+	logger := state.State.Logger
+	defer logger.Sync()
+
+	switch mimeType {
+	case "audio/mp4", "audio/m4a", "audio/x-m4a", "audio/aac":
+		return audioData, mimeType
+	}
+
+	convertedData, err := Converter.AudioToM4a(audioData)
+	if err != nil {
+		if !errors.Is(err, ErrConversionUnsupported) {
+			logger.Warn("failed to convert audio, sending it as it is",
+				zap.String("mime_type", mimeType),
+				zap.Error(err),
+			)
+		}
+		return audioData, mimeType
+	}
+	return convertedData, "audio/mp4"
+}
+
+// ConvertVideoNote crops a video note into a square which fits the limits of video notes.
+// It is returned as it was if it cannot be converted.
+func ConvertVideoNote(videoData []byte) []byte {
+	logger := state.State.Logger
+	defer logger.Sync()
+
+	convertedData, err := Converter.VideoToVideoNote(videoData)
+	if err != nil {
+		if !errors.Is(err, ErrConversionUnsupported) {
+			logger.Warn("failed to convert video note, sending it as it is",
+				zap.Error(err),
+			)
+		}
+		return videoData
+	}
+	return convertedData
+}
+
+// audioWaveform makes the 64 values between 0 and 100 which WhatsApp shows for voice
+// notes out of mono signed 16 bit little endian samples
+func audioWaveform(pcmData []byte) []byte {
+	const bars = 64
+
+	samplesCount := len(pcmData) / 2
+	if samplesCount < bars {
+		return nil
+	}
+
+	var (
+		levels   = make([]float64, bars)
+		maxLevel float64
+	)
+	for i := range levels {
+		start, end := i*samplesCount/bars, (i+1)*samplesCount/bars
+		var sum float64
+		for j := start; j < end; j++ {
+			sum += math.Abs(float64(int16(binary.LittleEndian.Uint16(pcmData[2*j:]))))
+		}
+		levels[i] = sum / float64(end-start)
+		if levels[i] > maxLevel {
+			maxLevel = levels[i]
+		}
+	}
+
+	waveform := make([]byte, bars)
+	if maxLevel == 0 {
+		return waveform
+	}
+	for i, level := range levels {
+		waveform[i] = byte(math.Round(level / maxLevel * 100))
+	}
+	return waveform
+}
+
+// makeTempDir makes a directory of its own for the files of a conversion, which the
+// caller has to remove
+func makeTempDir() (string, error) {
+	return os.MkdirTemp("", "watgbridge-")
+}
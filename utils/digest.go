@@ -0,0 +1,268 @@
+package utils
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"watgbridge/database"
+	"watgbridge/state"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"go.uber.org/zap"
+)
+
+// WaGetMessageMediaType returns a short description of the kind of media in the
+// message, or an empty string for text messages
+func WaGetMessageMediaType(msg *waProto.Message) string {
+	switch {
+	case msg.GetImageMessage() != nil:
+		return "photo"
+	case msg.GetVideoMessage() != nil && msg.GetVideoMessage().GetGifPlayback():
+		return "GIF"
+	case msg.GetVideoMessage() != nil:
+		return "video"
+	case msg.GetPtvMessage() != nil:
+		return "video note"
+	case msg.GetAudioMessage() != nil && msg.GetAudioMessage().GetPtt():
+		return "voice note"
+	case msg.GetAudioMessage() != nil:
+		return "audio"
+	case msg.GetDocumentMessage() != nil:
+		return "document"
+	case msg.GetStickerMessage() != nil:
+		return "sticker"
+	case msg.GetContactMessage() != nil || msg.GetContactsArrayMessage() != nil:
+		return "contact"
+	case msg.GetLocationMessage() != nil || msg.GetLiveLocationMessage() != nil:
+		return "location"
+	case msg.GetPollCreationMessage() != nil || msg.GetPollCreationMessageV2() != nil || msg.GetPollCreationMessageV3() != nil:
+		return "poll"
+	}
+	return ""
+}
+
+// WaGetMessageText returns the text of the message or the caption of its media
+func WaGetMessageText(msg *waProto.Message) string {
+	switch {
+	case msg.GetConversation() != "":
+		return msg.GetConversation()
+	case msg.GetExtendedTextMessage() != nil:
+		return msg.GetExtendedTextMessage().GetText()
+	case msg.GetImageMessage() != nil:
+		return msg.GetImageMessage().GetCaption()
+	case msg.GetVideoMessage() != nil:
+		return msg.GetVideoMessage().GetCaption()
+	case msg.GetDocumentMessage() != nil:
+		return msg.GetDocumentMessage().GetCaption()
+	}
+	return ""
+}
+
+// WaHoldMessage stores the message to be sent later in a digest
+func WaHoldMessage(account *state.WhatsAppAccount, waChatId string, v *events.Message) error {
+	return database.HeldMessageAdd(account.Name, waChatId, v.Info.ID, v.Info.MessageSource.Sender.ToNonAD().String(),
+		v.Info.Timestamp.UTC(), WaGetMessageMediaType(v.Message), WaGetMessageText(v.Message))
+}
+
+const (
+	digestTitleMuted    = "were received while the chat was muted"
+	digestTitleInterval = "were received since the last digest"
+)
+
+// TgSendHeldMessagesDigest sends the messages held for the chat to its topic, as a
+// summary of their senders and media followed by snippets of their text. Each
+// message is paired with the Telegram message it is listed in so that it can be
+// replied to.
+func TgSendHeldMessagesDigest(account *state.WhatsAppAccount, waChatId, title string) error {
+	var (
+		cfg   = state.State.Config
+		tgBot = state.State.TelegramBot
+	)
+
+	heldMessages, err := database.HeldMessageGetAll(account.Name, waChatId)
+	if err != nil || len(heldMessages) == 0 {
+		return err
+	}
+
+	chat, _ := WaParseJID(waChatId)
+	var threadName string
+	if chat.Server == types.GroupServer {
+		threadName = WaGetGroupName(account, chat)
+	} else {
+		threadName = WaGetContactName(chat)
+	}
+	targetChatId := WaGetTargetChat(account, chat, chat)
+	threadId, err := TgGetOrMakeThreadFromWa(account, waChatId, targetChatId, threadName)
+	if err != nil {
+		return err
+	}
+
+	var (
+		senders, mediaTypes           []string
+		senderCounts, mediaTypeCounts = make(map[string]int), make(map[string]int)
+	)
+	for _, heldMessage := range heldMessages {
+		if senderCounts[heldMessage.SenderId] == 0 {
+			senders = append(senders, heldMessage.SenderId)
+		}
+		senderCounts[heldMessage.SenderId]++
+		if heldMessage.MediaType != "" {
+			if mediaTypeCounts[heldMessage.MediaType] == 0 {
+				mediaTypes = append(mediaTypes, heldMessage.MediaType)
+			}
+			mediaTypeCounts[heldMessage.MediaType]++
+		}
+	}
+
+	digestText := fmt.Sprintf("<b>#Digest</b>\n<b>%v</b> messages %s\n", len(heldMessages), title)
+	if chat.Server == types.GroupServer {
+		var senderSummary []string
+		for _, senderId := range senders {
+			sender, _ := WaParseJID(senderId)
+			senderSummary = append(senderSummary, fmt.Sprintf("%s (%v)",
+				html.EscapeString(WaGetContactName(sender)), senderCounts[senderId]))
+		}
+		digestText += "<b>From:</b> " + strings.Join(senderSummary, ", ") + "\n"
+	}
+	if len(mediaTypes) > 0 {
+		var mediaSummary []string
+		for _, mediaType := range mediaTypes {
+			count := mediaTypeCounts[mediaType]
+			if count > 1 {
+				mediaSummary = append(mediaSummary, fmt.Sprintf("%v %ss", count, html.EscapeString(mediaType)))
+			} else {
+				mediaSummary = append(mediaSummary, fmt.Sprintf("%v %s", count, html.EscapeString(mediaType)))
+			}
+		}
+		digestText += "<b>Media:</b> " + strings.Join(mediaSummary, ", ") + "\n"
+	}
+	digestText += "\n"
+
+	// The messages listed in the part of the digest being built
+	var listedMessages []database.HeldMessage
+	sendDigestPart := func() error {
+		sentMsg, err := tgBot.SendMessage(targetChatId, digestText, &gotgbot.SendMessageOpts{
+			MessageThreadId: threadId,
+		})
+		if err != nil {
+			return err
+		}
+		for _, heldMessage := range listedMessages {
+			err = database.MsgIdAddNewPair(account.Name, heldMessage.WaMsgId, heldMessage.SenderId, waChatId,
+				targetChatId, sentMsg.MessageId, threadId)
+			if err != nil {
+				return err
+			}
+		}
+		// Deleted with each part, so that a part which fails does not resend the ones
+		// before it
+		if err = database.HeldMessageDelete(listedMessages); err != nil {
+			return err
+		}
+		digestText, listedMessages = "", nil
+		return nil
+	}
+
+	for _, heldMessage := range heldMessages {
+		sender, _ := WaParseJID(heldMessage.SenderId)
+		line := fmt.Sprintf("<b>%s</b> [%s]: ",
+			html.EscapeString(WaGetContactName(sender)),
+			html.EscapeString(heldMessage.Timestamp.In(state.State.LocalLocation).Format(cfg.TimeFormat)))
+		if heldMessage.MediaType != "" {
+			line += fmt.Sprintf("<i>%s</i> ", html.EscapeString(heldMessage.MediaType))
+		}
+		if len([]rune(heldMessage.Text)) > 200 {
+			line += html.EscapeString(SubString(heldMessage.Text, 0, 200)) + "..."
+		} else {
+			line += html.EscapeString(heldMessage.Text)
+		}
+		line += "\n"
+
+		if len(digestText)+len(line) > 4000 {
+			if err = sendDigestPart(); err != nil {
+				return err
+			}
+		}
+		digestText += line
+		listedMessages = append(listedMessages, heldMessage)
+	}
+	return sendDigestPart()
+}
+
+// TgSendIntervalDigests sends the digests of the chats in digest mode, except the
+// muted ones whose messages wait for the mute to end
+func TgSendIntervalDigests() {
+	logger := state.State.Logger
+	defer logger.Sync()
+
+	digestChats, err := database.ChatSettingsGetDigestChats()
+	if err != nil {
+		logger.Error("failed to get the chats in digest mode",
+			zap.Error(err),
+		)
+		return
+	}
+
+	for _, stored := range digestChats {
+		account := state.State.WhatsAppAccountByName(stored.Account)
+		if account == nil {
+			continue
+		}
+
+		settings, _, err := waGetChatSettings(account, stored.ID)
+		if err == nil && settings.Muted {
+			continue
+		}
+		if err == nil {
+			err = TgSendHeldMessagesDigest(account, stored.ID, digestTitleInterval)
+		}
+		if err != nil {
+			logger.Error("failed to send the digest of a chat",
+				zap.String("account", account.Name),
+				zap.String("chat_jid", stored.ID),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+// WaEndExpiredMutes unmutes the chats whose timed mutes have ended, which
+// sends the digests of the messages held for them
+func WaEndExpiredMutes() {
+	logger := state.State.Logger
+	defer logger.Sync()
+
+	expiredMutes, err := database.ChatSettingsGetExpiredMutes()
+	if err != nil {
+		logger.Error("failed to get the chats whose mutes have ended",
+			zap.Error(err),
+		)
+		return
+	}
+
+	for _, settings := range expiredMutes {
+		account := state.State.WhatsAppAccountByName(settings.Account)
+		if account == nil {
+			continue
+		}
+
+		// The chat goes back to its default setting
+		if err = database.ChatSettingsSet(account.Name, settings.ID, "MutedUntil", nil); err == nil {
+			err = database.ChatSettingsSet(account.Name, settings.ID, "Muted", nil)
+		}
+		if err == nil {
+			err = TgSendHeldMessagesDigest(account, settings.ID, digestTitleMuted)
+		}
+		if err != nil {
+			logger.Error("failed to end the mute of a chat",
+				zap.String("account", account.Name),
+				zap.String("chat_jid", settings.ID),
+				zap.Error(err),
+			)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"watgbridge/database"
+	"watgbridge/state"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"go.mau.fi/whatsmeow"
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+)
+
+// DisappearingTimers are the timers which can be set with the /disappearing command
+var DisappearingTimers = map[string]time.Duration{
+	"off": 0,
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+	"90d": 90 * 24 * time.Hour,
+}
+
+// WaFormatDisappearingTimer describes the timer like "7 days" or "off"
+func WaFormatDisappearingTimer(seconds uint32) string {
+	switch {
+	case seconds == 0:
+		return "off"
+	case seconds%(24*60*60) == 0:
+		return fmt.Sprintf("%v days", seconds/(24*60*60))
+	case seconds%(60*60) == 0:
+		return fmt.Sprintf("%v hours", seconds/(60*60))
+	}
+	return (time.Duration(seconds) * time.Second).String()
+}
+
+// WaGetMessageContextInfo returns the context info of whichever kind of message it is
+func WaGetMessageContextInfo(msg *waProto.Message) *waProto.ContextInfo {
+	switch {
+	case msg.GetExtendedTextMessage() != nil:
+		return msg.GetExtendedTextMessage().GetContextInfo()
+	case msg.GetImageMessage() != nil:
+		return msg.GetImageMessage().GetContextInfo()
+	case msg.GetVideoMessage() != nil:
+		return msg.GetVideoMessage().GetContextInfo()
+	case msg.GetPtvMessage() != nil:
+		return msg.GetPtvMessage().GetContextInfo()
+	case msg.GetAudioMessage() != nil:
+		return msg.GetAudioMessage().GetContextInfo()
+	case msg.GetDocumentMessage() != nil:
+		return msg.GetDocumentMessage().GetContextInfo()
+	case msg.GetStickerMessage() != nil:
+		return msg.GetStickerMessage().GetContextInfo()
+	case msg.GetContactMessage() != nil:
+		return msg.GetContactMessage().GetContextInfo()
+	case msg.GetContactsArrayMessage() != nil:
+		return msg.GetContactsArrayMessage().GetContextInfo()
+	case msg.GetLocationMessage() != nil:
+		return msg.GetLocationMessage().GetContextInfo()
+	case msg.GetLiveLocationMessage() != nil:
+		return msg.GetLiveLocationMessage().GetContextInfo()
+	}
+	return nil
+}
+
+// waSetMessageExpiration makes the message disappear after the given number of seconds,
+// plain text messages being turned into extended ones which can carry context info
+func waSetMessageExpiration(msg *waProto.Message, seconds uint32) {
+	if msg.Conversation != nil {
+		msg.ExtendedTextMessage = &waProto.ExtendedTextMessage{Text: msg.Conversation}
+		msg.Conversation = nil
+	}
+
+	contextInfo := WaGetMessageContextInfo(msg)
+	if contextInfo == nil {
+		contextInfo = &waProto.ContextInfo{}
+		switch {
+		case msg.ExtendedTextMessage != nil:
+			msg.ExtendedTextMessage.ContextInfo = contextInfo
+		case msg.ImageMessage != nil:
+			msg.ImageMessage.ContextInfo = contextInfo
+		case msg.VideoMessage != nil:
+			msg.VideoMessage.ContextInfo = contextInfo
+		case msg.PtvMessage != nil:
+			msg.PtvMessage.ContextInfo = contextInfo
+		case msg.AudioMessage != nil:
+			msg.AudioMessage.ContextInfo = contextInfo
+		case msg.DocumentMessage != nil:
+			msg.DocumentMessage.ContextInfo = contextInfo
+		case msg.StickerMessage != nil:
+			msg.StickerMessage.ContextInfo = contextInfo
+		case msg.ContactMessage != nil:
+			msg.ContactMessage.ContextInfo = contextInfo
+		case msg.ContactsArrayMessage != nil:
+			msg.ContactsArrayMessage.ContextInfo = contextInfo
+		case msg.LocationMessage != nil:
+			msg.LocationMessage.ContextInfo = contextInfo
+		case msg.LiveLocationMessage != nil:
+			msg.LiveLocationMessage.ContextInfo = contextInfo
+		default:
+			return
+		}
+	}
+	contextInfo.Expiration = proto.Uint32(seconds)
+}
+
+// WaGetDisappearingTimer returns the disappearing messages timer of the chat in seconds,
+// asking WhatsApp for the timers of groups which have not been learnt yet
+func WaGetDisappearingTimer(account *state.WhatsAppAccount, chat types.JID) (uint32, error) {
+	waChatId := chat.ToNonAD().String()
+
+	seconds, found, err := database.DisappearingTimerGet(account.Name, waChatId)
+	if err != nil || found || chat.Server != types.GroupServer {
+		return seconds, err
+	}
+
+	groupInfo, err := account.Client.GetGroupInfo(chat)
+	if err != nil {
+		return 0, err
+	}
+	return groupInfo.DisappearingTimer, database.DisappearingTimerSet(account.Name, waChatId, groupInfo.DisappearingTimer)
+}
+
+// WaUpdateDisappearingTimer stores the new timer of the chat, and if notify is set and the
+// timer has changed, says so in the topic of the chat
+func WaUpdateDisappearingTimer(account *state.WhatsAppAccount, chat types.JID, seconds uint32, notify bool) error {
+	waChatId := chat.ToNonAD().String()
+
+	oldSeconds, found, err := database.DisappearingTimerGet(account.Name, waChatId)
+	if err != nil {
+		return err
+	} else if found && oldSeconds == seconds {
+		return nil
+	}
+
+	if err = database.DisappearingTimerSet(account.Name, waChatId, seconds); err != nil {
+		return err
+	}
+	if !notify {
+		return nil
+	}
+
+	var threadName string
+	if chat.Server == types.GroupServer {
+		threadName = WaGetGroupName(account, chat)
+	} else {
+		threadName = WaGetContactName(chat)
+	}
+	targetChatId := WaGetTargetChat(account, chat, chat)
+	threadId, err := TgGetOrMakeThreadFromWa(account, waChatId, targetChatId, threadName)
+	if err != nil {
+		return err
+	}
+
+	if seconds == 0 {
+		return TgSendTextById(state.State.TelegramBot, targetChatId, threadId,
+			"<b>#Disappearing</b>\nDisappearing messages were turned off")
+	}
+	return TgSendTextById(state.State.TelegramBot, targetChatId, threadId,
+		fmt.Sprintf("<b>#Disappearing</b>\nNew messages will disappear after <b>%s</b>", WaFormatDisappearingTimer(seconds)))
+}
+
+// WaSendMessage sends the message to the chat, setting it to disappear if the chat has
+// disappearing messages turned on. Statuses are posted to the configured audience.
+func WaSendMessage(account *state.WhatsAppAccount, chat types.JID, msg *waProto.Message) (whatsmeow.SendResponse, error) {
+	logger := state.State.Logger
+	defer logger.Sync()
+
+	if chat == types.StatusBroadcastJID {
+		return waSendStatus(account, msg)
+	}
+
+	if chat.Server == types.DefaultUserServer || chat.Server == types.GroupServer {
+		seconds, err := WaGetDisappearingTimer(account, chat)
+		if err != nil {
+			logger.Warn("failed to get the disappearing messages timer of the chat",
+				zap.String("chat_jid", chat.String()),
+				zap.Error(err),
+			)
+		} else if seconds > 0 {
+			waSetMessageExpiration(msg, seconds)
+		}
+	}
+
+	return account.Client.SendMessage(context.Background(), chat, msg)
+}
+
+// TgDeleteExpiredCopies deletes the Telegram copies of the disappearing messages which
+// have expired on WhatsApp
+func TgDeleteExpiredCopies() {
+	var (
+		logger = state.State.Logger
+		tgBot  = state.State.TelegramBot
+	)
+	defer logger.Sync()
+
+	expiredCopies, err := database.ExpiringCopyGetExpired()
+	if err != nil {
+		logger.Error("failed to get the expired copies of disappearing messages",
+			zap.Error(err),
+		)
+		return
+	}
+
+	for _, expiredCopy := range expiredCopies {
+		// Messages older than 48 hours cannot be deleted by bots, they are not retried
+		_, err = tgBot.DeleteMessage(expiredCopy.TgChatId, expiredCopy.TgMsgId, &gotgbot.DeleteMessageOpts{})
+		if err != nil {
+			logger.Warn("failed to delete the copy of an expired message",
+				zap.Int64("chat_id", expiredCopy.TgChatId),
+				zap.Int64("msg_id", expiredCopy.TgMsgId),
+				zap.Error(err),
+			)
+		}
+		if err = database.ExpiringCopyDelete(expiredCopy.ID); err != nil {
+			logger.Error("failed to remove the copy of an expired message from the database",
+				zap.Error(err),
+			)
+		}
+	}
+}
@@ -0,0 +1,389 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"watgbridge/state"
+
+	"go.uber.org/zap"
+)
+
+var (
+	ffmpegDurationRegex  = regexp.MustCompile(`Duration: (\d+):(\d+):(\d+(?:\.\d+)?)`)
+	ffmpegDimensionRegex = regexp.MustCompile(`Stream #.*: Video: .*?, (\d+)x(\d+)`)
+	ffmpegRotationRegex  = regexp.MustCompile(`rotation of (-?\d+(?:\.\d+)?) degrees`)
+)
+
+// FfmpegConverter converts media with ffmpeg, and ImageMagick for animated webp
+// which ffmpeg cannot decode
+type FfmpegConverter struct {
+	FfmpegExecutable string
+}
+
+func (conv *FfmpegConverter) StickerToWebp(inputData []byte) ([]byte, error) {
+	currPath, err := makeTempDir()
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(currPath)
+
+	var (
+		inputPath  = path.Join(currPath, "input")
+		outputPath = path.Join(currPath, "output.webp")
+	)
+
+	if err := os.WriteFile(inputPath, inputData, 0644); err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(conv.FfmpegExecutable,
+		"-i", inputPath,
+		"-fs", "800000",
+		"-vf", "fps=15,scale=512:512:force_original_aspect_ratio=decrease,format=rgba,pad=512:512:(ow-iw)/2:(oh-ih)/2:color=#00000000",
+		outputPath,
+	)
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to execute ffmpeg command: %s", err)
+	}
+
+	return os.ReadFile(outputPath)
+}
+
+func (conv *FfmpegConverter) AnimatedWebpToGif(inputData []byte) ([]byte, error) {
+	logger := state.State.Logger
+	defer logger.Sync()
+
+	currPath, err := makeTempDir()
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(currPath)
+
+	var (
+		inputPath  = path.Join(currPath, "input.webp")
+		outputPath = path.Join(currPath, "output.gif")
+	)
+
+	if err := os.WriteFile(inputPath, inputData, 0644); err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command("convert",
+		inputPath,
+		"-loop", "0",
+		"-dispose", "previous",
+		outputPath,
+	)
+
+	if err := cmd.Run(); err != nil {
+		logger.Debug("failed to run convert command",
+			zap.Error(err),
+		)
+		return nil, err
+	}
+
+	return os.ReadFile(outputPath)
+}
+
+func (conv *FfmpegConverter) AnimatedWebpToWebm(inputData []byte) ([]byte, error) {
+	logger := state.State.Logger
+	defer logger.Sync()
+
+	currPath, err := makeTempDir()
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(currPath)
+
+	var (
+		inputPath  = path.Join(currPath, "input.webp")
+		concatPath = path.Join(currPath, "frames.txt")
+		outputPath = path.Join(currPath, "output.webm")
+	)
+
+	if err := os.WriteFile(inputPath, inputData, 0644); err != nil {
+		return nil, err
+	}
+
+	// Frame delays are in hundredths of a second
+	delaysOutput, err := exec.Command("identify", "-format", "%T\n", inputPath).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute identify command: %s", err)
+	}
+	delays := strings.Fields(string(delaysOutput))
+	if len(delays) == 0 {
+		return nil, fmt.Errorf("sticker has no frames")
+	}
+
+	cmd := exec.Command("convert", inputPath, "-coalesce", path.Join(currPath, "frame_%04d.png"))
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to execute convert command: %s", err)
+	}
+
+	var (
+		concatList    strings.Builder
+		totalDuration float64
+	)
+	for i, delay := range delays {
+		duration, err := strconv.ParseFloat(delay, 64)
+		if err != nil || duration <= 0 {
+			duration = 10
+		}
+		duration /= 100
+		totalDuration += duration
+		fmt.Fprintf(&concatList, "file 'frame_%04d.png'\nduration %.2f\n", i, duration)
+	}
+	// The last frame has to be repeated for its duration to be used
+	fmt.Fprintf(&concatList, "file 'frame_%04d.png'\n", len(delays)-1)
+	if err := os.WriteFile(concatPath, []byte(concatList.String()), 0644); err != nil {
+		return nil, err
+	}
+
+	// Longer stickers are sped up rather than cut off
+	speed := 1.0
+	if totalDuration > 3 {
+		speed = 2.9 / totalDuration
+	}
+
+	for _, crf := range []string{"30", "40", "50", "63"} {
+		logger.Debug("trying to convert animated webp to webm",
+			zap.String("crf", crf),
+		)
+
+		cmd := exec.Command(conv.FfmpegExecutable,
+			"-y",
+			"-f", "concat", "-safe", "0",
+			"-i", concatPath,
+			"-vf", fmt.Sprintf("setpts=%.4f*PTS,fps=30,scale=512:512:force_original_aspect_ratio=decrease:force_divisible_by=2", speed),
+			"-t", "3",
+			"-an",
+			"-c:v", "libvpx-vp9",
+			"-pix_fmt", "yuva420p",
+			"-crf", crf, "-b:v", "0",
+			outputPath,
+		)
+		if err := cmd.Run(); err != nil {
+			return nil, fmt.Errorf("failed to execute ffmpeg command: %s", err)
+		}
+
+		outputData, err := os.ReadFile(outputPath)
+		if err != nil {
+			return nil, err
+		} else if len(outputData) <= 256*1024 {
+			return outputData, nil
+		}
+	}
+	return nil, fmt.Errorf("sticker has a lot of data which cannot be handled by Telegram")
+}
+
+func (conv *FfmpegConverter) AudioToVoice(inputData []byte) ([]byte, uint32, []byte, error) {
+	currPath, err := makeTempDir()
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	defer os.RemoveAll(currPath)
+
+	var (
+		inputPath  = path.Join(currPath, "input")
+		outputPath = path.Join(currPath, "output.ogg")
+	)
+
+	if err := os.WriteFile(inputPath, inputData, 0644); err != nil {
+		return nil, 0, nil, err
+	}
+
+	cmd := exec.Command(conv.FfmpegExecutable,
+		"-i", inputPath,
+		"-vn",
+		"-ac", "1", "-ar", "48000",
+		"-c:a", "libopus", "-b:a", "32k", "-application", "voip",
+		outputPath,
+	)
+	if err := cmd.Run(); err != nil {
+		return nil, 0, nil, fmt.Errorf("failed to execute ffmpeg command: %s", err)
+	}
+
+	outputData, err := os.ReadFile(outputPath)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	// The waveform and duration are taken from the samples at 8 kHz
+	pcmData, err := exec.Command(conv.FfmpegExecutable,
+		"-i", outputPath,
+		"-ac", "1", "-ar", "8000",
+		"-f", "s16le", "-",
+	).Output()
+	if err != nil {
+		return outputData, 0, nil, nil
+	}
+
+	seconds := uint32((len(pcmData)/2 + 7999) / 8000)
+	return outputData, seconds, audioWaveform(pcmData), nil
+}
+
+func (conv *FfmpegConverter) AudioToM4a(inputData []byte) ([]byte, error) {
+	currPath, err := makeTempDir()
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(currPath)
+
+	var (
+		inputPath  = path.Join(currPath, "input")
+		outputPath = path.Join(currPath, "output.m4a")
+	)
+
+	if err := os.WriteFile(inputPath, inputData, 0644); err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(conv.FfmpegExecutable,
+		"-i", inputPath,
+		"-vn",
+		"-c:a", "aac", "-b:a", "128k",
+		"-movflags", "+faststart",
+		outputPath,
+	)
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to execute ffmpeg command: %s", err)
+	}
+
+	return os.ReadFile(outputPath)
+}
+
+func (conv *FfmpegConverter) VideoToVideoNote(inputData []byte) ([]byte, error) {
+	currPath, err := makeTempDir()
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(currPath)
+
+	var (
+		inputPath  = path.Join(currPath, "input")
+		outputPath = path.Join(currPath, "output.mp4")
+	)
+
+	if err := os.WriteFile(inputPath, inputData, 0644); err != nil {
+		return nil, err
+	}
+
+	// The centre of the video is cropped into a square, whose side is made even for H.264
+	cmd := exec.Command(conv.FfmpegExecutable,
+		"-i", inputPath,
+		"-t", strconv.Itoa(VideoNoteMaxSeconds),
+		"-vf", fmt.Sprintf("crop='min(iw,ih)':'min(iw,ih)',scale='trunc(min(%d,iw)/2)*2':'trunc(min(%d,iw)/2)*2'",
+			VideoNoteMaxLength, VideoNoteMaxLength),
+		"-c:v", "libx264", "-preset", "veryfast", "-crf", "28",
+		"-maxrate", "1500k", "-bufsize", "3000k",
+		"-pix_fmt", "yuv420p",
+		"-c:a", "aac", "-b:a", "64k", "-ac", "1",
+		"-movflags", "+faststart",
+		outputPath,
+	)
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to execute ffmpeg command: %s", err)
+	}
+
+	outputData, err := os.ReadFile(outputPath)
+	if err != nil {
+		return nil, err
+	} else if len(outputData) > VideoNoteSizeLimit {
+		return nil, fmt.Errorf("video note is too large even after conversion")
+	}
+	return outputData, nil
+}
+
+func (conv *FfmpegConverter) ProbeVideo(inputData []byte) (*VideoInfo, error) {
+	currPath, err := makeTempDir()
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(currPath)
+
+	var (
+		inputPath  = path.Join(currPath, "input")
+		outputPath = path.Join(currPath, "frame.png")
+	)
+
+	if err := os.WriteFile(inputPath, inputData, 0644); err != nil {
+		return nil, err
+	}
+
+	// ffmpeg prints what it knows of the input while taking the frame, which is read
+	// from there as ffprobe may not be installed alongside it
+	var stderr strings.Builder
+	cmd := exec.Command(conv.FfmpegExecutable,
+		"-i", inputPath,
+		"-frames:v", "1",
+		"-f", "image2", "-c:v", "png",
+		outputPath,
+	)
+	cmd.Stderr = &stderr
+	runErr := cmd.Run()
+
+	var (
+		output = stderr.String()
+		info   = &VideoInfo{}
+	)
+	if match := ffmpegDimensionRegex.FindStringSubmatch(output); match != nil {
+		info.Width, _ = strconv.Atoi(match[1])
+		info.Height, _ = strconv.Atoi(match[2])
+	} else {
+		return nil, fmt.Errorf("failed to find the video stream: %s", runErr)
+	}
+	// Videos recorded in portrait are often stored in landscape with a rotation
+	if match := ffmpegRotationRegex.FindStringSubmatch(output); match != nil {
+		if rotation, _ := strconv.ParseFloat(match[1], 64); math.Mod(math.Abs(rotation), 180) == 90 {
+			info.Width, info.Height = info.Height, info.Width
+		}
+	}
+	if match := ffmpegDurationRegex.FindStringSubmatch(output); match != nil {
+		hours, _ := strconv.Atoi(match[1])
+		minutes, _ := strconv.Atoi(match[2])
+		seconds, _ := strconv.ParseFloat(match[3], 64)
+		info.Seconds = uint32(math.Round(float64(hours*3600+minutes*60) + seconds))
+	}
+
+	if runErr == nil {
+		info.FirstFrame, _ = os.ReadFile(outputPath)
+	}
+	return info, nil
+}
+
+func (conv *FfmpegConverter) PdfFirstPage(inputData []byte) ([]byte, error) {
+	currPath, err := makeTempDir()
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(currPath)
+
+	var (
+		inputPath  = path.Join(currPath, "input.pdf")
+		outputPath = path.Join(currPath, "output.png")
+	)
+
+	if err := os.WriteFile(inputPath, inputData, 0644); err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command("convert",
+		"-density", "72",
+		inputPath+"[0]",
+		"-background", "white", "-alpha", "remove",
+		outputPath,
+	)
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to execute convert command: %s", err)
+	}
+
+	return os.ReadFile(outputPath)
+}
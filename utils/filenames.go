@@ -0,0 +1,179 @@
+package utils
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Longest file name, in bytes, which is kept when sanitising names
+const maxFileNameLength = 200
+
+// Extensions of the MIME types seen on either side, which are preferred over the ones
+// of the system as those differ between distributions
+var mimeTypeExtensions = map[string]string{
+	"application/gzip":                        ".gz",
+	"application/json":                        ".json",
+	"application/msword":                      ".doc",
+	"application/ogg":                         ".ogg",
+	"application/pdf":                         ".pdf",
+	"application/vnd.android.package-archive": ".apk",
+	"application/vnd.ms-excel":                ".xls",
+	"application/vnd.ms-powerpoint":           ".ppt",
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation": ".pptx",
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":         ".xlsx",
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document":   ".docx",
+	"application/x-7z-compressed":                                               ".7z",
+	"application/x-rar-compressed":                                              ".rar",
+	"application/x-tgsticker":                                                   ".tgs",
+	"application/zip":                                                           ".zip",
+	"audio/aac":                                                                 ".aac",
+	"audio/amr":                                                                 ".amr",
+	"audio/flac":                                                                ".flac",
+	"audio/m4a":                                                                 ".m4a",
+	"audio/mp4":                                                                 ".m4a",
+	"audio/mpeg":                                                                ".mp3",
+	"audio/ogg":                                                                 ".ogg",
+	"audio/opus":                                                                ".opus",
+	"audio/wav":                                                                 ".wav",
+	"audio/wave":                                                                ".wav",
+	"audio/webm":                                                                ".webm",
+	"audio/x-m4a":                                                               ".m4a",
+	"audio/x-wav":                                                               ".wav",
+	"image/bmp":                                                                 ".bmp",
+	"image/gif":                                                                 ".gif",
+	"image/heic":                                                                ".heic",
+	"image/jpeg":                                                                ".jpg",
+	"image/png":                                                                 ".png",
+	"image/svg+xml":                                                             ".svg",
+	"image/tiff":                                                                ".tiff",
+	"image/webp":                                                                ".webp",
+	"text/csv":                                                                  ".csv",
+	"text/html":                                                                 ".html",
+	"text/plain":                                                                ".txt",
+	"text/vcard":                                                                ".vcf",
+	"text/x-vcard":                                                              ".vcf",
+	"video/3gpp":                                                                ".3gp",
+	"video/mp4":                                                                 ".mp4",
+	"video/mpeg":                                                                ".mpeg",
+	"video/quicktime":                                                           ".mov",
+	"video/webm":                                                                ".webm",
+	"video/x-matroska":                                                          ".mkv",
+	"video/x-msvideo":                                                           ".avi",
+}
+
+// BaseMimeType returns the MIME type without its parameters, in lower case
+func BaseMimeType(mimeType string) string {
+	if mediaType, _, err := mime.ParseMediaType(mimeType); err == nil {
+		return mediaType
+	}
+	mediaType, _, _ := strings.Cut(mimeType, ";")
+	return strings.ToLower(strings.TrimSpace(mediaType))
+}
+
+// DetectMimeType returns the given MIME type of the file, or the one found from its
+// contents if none was given or it is only the generic binary type
+func DetectMimeType(data []byte, mimeType string) string {
+	if mimeType != "" && BaseMimeType(mimeType) != "application/octet-stream" {
+		return mimeType
+	}
+
+	// Formats which net/http does not recognise, or reports too vaguely
+	switch {
+	case len(data) >= 12 && string(data[4:8]) == "ftyp":
+		switch string(data[8:12]) {
+		case "M4A ", "M4B ":
+			return "audio/mp4"
+		case "qt  ":
+			return "video/quicktime"
+		case "3gp4", "3gp5", "3gp6":
+			return "video/3gpp"
+		}
+		return "video/mp4"
+	case bytes.HasPrefix(data, []byte("OggS")):
+		if bytes.Contains(data[:clampInt(len(data), 0, 64)], []byte("OpusHead")) {
+			return "audio/ogg; codecs=opus"
+		}
+		return "audio/ogg"
+	case bytes.HasPrefix(data, []byte("#!AMR")):
+		return "audio/amr"
+	case bytes.HasPrefix(data, []byte("fLaC")):
+		return "audio/flac"
+	case bytes.HasPrefix(data, []byte("7z\xbc\xaf\x27\x1c")):
+		return "application/x-7z-compressed"
+	case bytes.HasPrefix(data, []byte("\x1a\x45\xdf\xa3")) && !bytes.Contains(data[:clampInt(len(data), 0, 64)], []byte("webm")):
+		return "video/x-matroska"
+	}
+
+	return http.DetectContentType(data)
+}
+
+// ExtensionForMimeType returns the extension, with its dot, of files of the MIME type,
+// or an empty string if it is not known
+func ExtensionForMimeType(mimeType string) string {
+	mediaType := BaseMimeType(mimeType)
+	if extension, found := mimeTypeExtensions[mediaType]; found {
+		return extension
+	}
+	if extensions, err := mime.ExtensionsByType(mediaType); err == nil && len(extensions) > 0 {
+		return extensions[0]
+	}
+	return ""
+}
+
+// SanitizeFileName removes directories, control characters and the characters which
+// are not allowed in file names on common systems, shortening names which are too long
+func SanitizeFileName(fileName string) string {
+	fileName = path.Base(strings.ReplaceAll(fileName, "\\", "/"))
+	if fileName == "/" {
+		// Names of only slashes have no base
+		fileName = ""
+	}
+
+	fileName = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == utf8.RuneError || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, fileName)
+	fileName = strings.Trim(fileName, " .")
+
+	if len(fileName) > maxFileNameLength {
+		extension := path.Ext(fileName)
+		if len(extension) > 16 {
+			extension = ""
+		}
+		stem := fileName[:maxFileNameLength-len(extension)]
+		for !utf8.ValidString(stem) {
+			stem = stem[:len(stem)-1]
+		}
+		fileName = strings.TrimRight(stem, " .") + extension
+	}
+	return fileName
+}
+
+// MakeFileName returns the sanitised name of the file, or the default name when it has
+// none, adding the extension of its MIME type when the name lacks one
+func MakeFileName(fileName, defaultName, mimeType string) string {
+	fileName = SanitizeFileName(fileName)
+	if fileName == "" {
+		fileName = defaultName
+	}
+	if path.Ext(fileName) == "" {
+		fileName += ExtensionForMimeType(mimeType)
+	}
+	return fileName
+}
+
+// FileNameTitle returns the file name without its extension, which WhatsApp shows as
+// the title of documents
+func FileNameTitle(fileName string) string {
+	if title := strings.TrimSuffix(fileName, path.Ext(fileName)); title != "" {
+		return title
+	}
+	return fileName
+}
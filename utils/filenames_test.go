@@ -0,0 +1,177 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSanitizeFileName(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		want     string
+	}{
+		{"plain name", "report.pdf", "report.pdf"},
+		{"empty", "", ""},
+		{"unix directories", "/home/user/report.pdf", "report.pdf"},
+		{"windows directories", `C:\Users\user\report.pdf`, "report.pdf"},
+		{"windows parent directory", `..\x`, "x"},
+		{"unix parent directory", "../../etc/passwd", "passwd"},
+		{"only slash", "/", ""},
+		{"only slashes", "///", ""},
+		{"only parent directory", "..", ""},
+		{"trailing slash", "reports/", "reports"},
+		{"reserved characters", `a<b>:c?"d"|e*.txt`, "a_b__c__d__e_.txt"},
+		{"control characters", "line\nbreak\t.txt", "line_break_.txt"},
+		{"invalid UTF-8", "bad\xffname.txt", "bad_name.txt"},
+		{"surrounding dots and spaces", " .hidden. ", "hidden"},
+		{"unicode kept", "résumé 履歴書.pdf", "résumé 履歴書.pdf"},
+		{
+			"long name keeps extension",
+			strings.Repeat("a", 250) + ".pdf",
+			strings.Repeat("a", maxFileNameLength-len(".pdf")) + ".pdf",
+		},
+		{
+			"long name cut at UTF-8 boundary",
+			strings.Repeat("a", 195) + strings.Repeat("é", 3) + ".pdf",
+			strings.Repeat("a", 195) + ".pdf",
+		},
+		{
+			"long name cut before trailing dots",
+			strings.Repeat("a", 190) + strings.Repeat(".", 20) + ".pdf",
+			strings.Repeat("a", 190) + ".pdf",
+		},
+		{
+			"over-long extension cut as part of the name",
+			strings.Repeat("a", 190) + "." + strings.Repeat("b", 20),
+			strings.Repeat("a", 190) + "." + strings.Repeat("b", 9),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := SanitizeFileName(test.fileName)
+			if got != test.want {
+				t.Errorf("SanitizeFileName(%q) = %q, want %q", test.fileName, got, test.want)
+			}
+			if len(got) > maxFileNameLength {
+				t.Errorf("SanitizeFileName(%q) is %v bytes long, longer than %v", test.fileName, len(got), maxFileNameLength)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("SanitizeFileName(%q) = %q, which is not valid UTF-8", test.fileName, got)
+			}
+		})
+	}
+}
+
+func TestMakeFileName(t *testing.T) {
+	tests := []struct {
+		name        string
+		fileName    string
+		defaultName string
+		mimeType    string
+		want        string
+	}{
+		{"name kept", "photo.png", "image", "image/jpeg", "photo.png"},
+		{"extension added", "report", "document", "application/pdf", "report.pdf"},
+		{"default name", "", "document", "application/pdf", "document.pdf"},
+		{"default name for traversal", `..\..\`, "document", "application/zip", "document.zip"},
+		{"default name for slash", "/", "document", "text/plain", "document.txt"},
+		{"parameters of type ignored", "voice", "audio", "audio/ogg; codecs=opus", "voice.ogg"},
+		{"unknown type", "data", "file", "application/x-watgbridge-unknown", "data"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := MakeFileName(test.fileName, test.defaultName, test.mimeType)
+			if got != test.want {
+				t.Errorf("MakeFileName(%q, %q, %q) = %q, want %q",
+					test.fileName, test.defaultName, test.mimeType, got, test.want)
+			}
+		})
+	}
+}
+
+func TestFileNameTitle(t *testing.T) {
+	tests := []struct {
+		fileName string
+		want     string
+	}{
+		{"report.pdf", "report"},
+		{"archive.tar.gz", "archive.tar"},
+		{"README", "README"},
+		{".pdf", ".pdf"},
+	}
+
+	for _, test := range tests {
+		if got := FileNameTitle(test.fileName); got != test.want {
+			t.Errorf("FileNameTitle(%q) = %q, want %q", test.fileName, got, test.want)
+		}
+	}
+}
+
+func TestDetectMimeType(t *testing.T) {
+	ftyp := func(brand string) []byte {
+		return append([]byte("\x00\x00\x00\x20ftyp"+brand), make([]byte, 20)...)
+	}
+	ebml := func(docType string) []byte {
+		return append([]byte("\x1a\x45\xdf\xa3\x9f\x42\x86\x81\x01\x42\x82\x84"+docType), make([]byte, 20)...)
+	}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		mimeType string
+		want     string
+	}{
+		{"given type kept", []byte("%PDF-1.7"), "application/pdf", "application/pdf"},
+		{"given type kept over contents", []byte("%PDF-1.7"), "image/png", "image/png"},
+		{"generic type replaced", []byte("%PDF-1.7"), "application/octet-stream", "application/pdf"},
+		{"mp4", ftyp("isom"), "", "video/mp4"},
+		{"m4a", ftyp("M4A "), "", "audio/mp4"},
+		{"audiobook", ftyp("M4B "), "", "audio/mp4"},
+		{"quicktime", ftyp("qt  "), "", "video/quicktime"},
+		{"3gpp", ftyp("3gp4"), "", "video/3gpp"},
+		{"too short for ftyp", []byte("\x00\x00\x00\x20ftyp"), "", "application/octet-stream"},
+		{"ogg opus", []byte("OggS\x00\x02\x00\x00\x00\x00\x00\x00\x00\x00\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x01\x13OpusHead"), "", "audio/ogg; codecs=opus"},
+		{"ogg vorbis", []byte("OggS\x00\x02\x00\x00\x00\x00\x00\x00\x00\x00\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x01\x1e\x01vorbis"), "", "audio/ogg"},
+		{"amr", []byte("#!AMR\n"), "", "audio/amr"},
+		{"flac", []byte("fLaC\x00\x00\x00\x22"), "", "audio/flac"},
+		{"7z", []byte("7z\xbc\xaf\x27\x1c\x00\x04"), "", "application/x-7z-compressed"},
+		{"matroska", ebml("matroska"), "", "video/x-matroska"},
+		{"webm", ebml("webm"), "", "video/webm"},
+		{"png", []byte("\x89PNG\r\n\x1a\n"), "", "image/png"},
+		{"unknown binary", []byte{0x00, 0x01, 0x02, 0x03}, "", "application/octet-stream"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := DetectMimeType(test.data, test.mimeType); got != test.want {
+				t.Errorf("DetectMimeType(%q, %q) = %q, want %q", test.data, test.mimeType, got, test.want)
+			}
+		})
+	}
+}
+
+func TestExtensionForMimeType(t *testing.T) {
+	tests := []struct {
+		mimeType string
+		want     string
+	}{
+		{"image/jpeg", ".jpg"},
+		{"IMAGE/JPEG", ".jpg"},
+		{"audio/ogg; codecs=opus", ".ogg"},
+		{"video/x-matroska", ".mkv"},
+		{"video/webm", ".webm"},
+		{"application/vnd.openxmlformats-officedocument.wordprocessingml.document", ".docx"},
+		{"application/x-watgbridge-unknown", ""},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		if got := ExtensionForMimeType(test.mimeType); got != test.want {
+			t.Errorf("ExtensionForMimeType(%q) = %q, want %q", test.mimeType, got, test.want)
+		}
+	}
+}
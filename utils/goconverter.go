@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+
+	"github.com/kolesa-team/go-webp/decoder"
+	"github.com/kolesa-team/go-webp/encoder"
+	"github.com/kolesa-team/go-webp/webp"
+)
+
+// GoConverter is the converter used when ffmpeg is not installed. It only handles
+// static images (webp, png, jpeg and the first frame of GIFs).
+type GoConverter struct{}
+
+func (conv *GoConverter) StickerToWebp(inputData []byte) ([]byte, error) {
+	inputImage, err := decodeStaticImage(inputData)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		inputWidth  = inputImage.Bounds().Dx()
+		inputHeight = inputImage.Bounds().Dy()
+		width       = 512
+		height      = 512
+	)
+	if inputWidth > inputHeight {
+		height = int(math.Round(float64(inputHeight) * 512 / float64(inputWidth)))
+	} else if inputHeight > inputWidth {
+		width = int(math.Round(float64(inputWidth) * 512 / float64(inputHeight)))
+	}
+	if width < 1 || height < 1 {
+		return nil, fmt.Errorf("image of %vx%v cannot be made into a sticker", inputWidth, inputHeight)
+	}
+
+	scaledImage := scaleImage(inputImage, width, height)
+	outputImage := image.NewRGBA(image.Rect(0, 0, 512, 512))
+	offset := image.Pt((512-width)/2, (512-height)/2)
+	draw.Draw(outputImage, scaledImage.Bounds().Add(offset), scaledImage, image.Point{}, draw.Src)
+
+	encoderOptions, err := encoder.NewLossyEncoderOptions(encoder.PresetDefault, 100)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize encoder options: %s", err)
+	}
+
+	var outputBuffer bytes.Buffer
+	if err = webp.Encode(&outputBuffer, outputImage, encoderOptions); err != nil {
+		return nil, fmt.Errorf("failed to encode into webp: %s", err)
+	}
+	return outputBuffer.Bytes(), nil
+}
+
+func (conv *GoConverter) AnimatedWebpToGif(inputData []byte) ([]byte, error) {
+	return nil, ErrConversionUnsupported
+}
+
+func (conv *GoConverter) AnimatedWebpToWebm(inputData []byte) ([]byte, error) {
+	return nil, ErrConversionUnsupported
+}
+
+func (conv *GoConverter) AudioToVoice(inputData []byte) ([]byte, uint32, []byte, error) {
+	return nil, 0, nil, ErrConversionUnsupported
+}
+
+func (conv *GoConverter) AudioToM4a(inputData []byte) ([]byte, error) {
+	return nil, ErrConversionUnsupported
+}
+
+func (conv *GoConverter) VideoToVideoNote(inputData []byte) ([]byte, error) {
+	return nil, ErrConversionUnsupported
+}
+
+func (conv *GoConverter) ProbeVideo(inputData []byte) (*VideoInfo, error) {
+	return nil, ErrConversionUnsupported
+}
+
+func (conv *GoConverter) PdfFirstPage(inputData []byte) ([]byte, error) {
+	return nil, ErrConversionUnsupported
+}
+
+// decodeStaticImage decodes webp, png, jpeg and GIF images, the formats of videos
+// being reported as unsupported
+func decodeStaticImage(inputData []byte) (image.Image, error) {
+	if len(inputData) >= 12 && string(inputData[0:4]) == "RIFF" && string(inputData[8:12]) == "WEBP" {
+		webpDecoder, err := decoder.NewDecoder(bytes.NewReader(inputData), &decoder.Options{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create a webp decoder: %s", err)
+		}
+		return webpDecoder.Decode()
+	}
+
+	inputImage, _, err := image.Decode(bytes.NewReader(inputData))
+	if errors.Is(err, image.ErrFormat) {
+		return nil, ErrConversionUnsupported
+	}
+	return inputImage, err
+}
+
+// scaleImage resizes the image with bilinear interpolation
+func scaleImage(src image.Image, width, height int) *image.RGBA {
+	var (
+		srcWidth  = src.Bounds().Dx()
+		srcHeight = src.Bounds().Dy()
+		srcRGBA   = image.NewRGBA(image.Rect(0, 0, srcWidth, srcHeight))
+		dst       = image.NewRGBA(image.Rect(0, 0, width, height))
+	)
+	// Colours are premultiplied by alpha, so that transparent pixels do not bleed into the edges
+	draw.Draw(srcRGBA, srcRGBA.Bounds(), src, src.Bounds().Min, draw.Src)
+
+	sample := func(size, srcSize, i int) (int, int, float64) {
+		f := (float64(i)+0.5)*float64(srcSize)/float64(size) - 0.5
+		i0 := int(math.Floor(f))
+		weight := f - float64(i0)
+		return clampInt(i0, 0, srcSize-1), clampInt(i0+1, 0, srcSize-1), weight
+	}
+
+	for y := 0; y < height; y++ {
+		y0, y1, wy := sample(height, srcHeight, y)
+		for x := 0; x < width; x++ {
+			x0, x1, wx := sample(width, srcWidth, x)
+			for c := 0; c < 4; c++ {
+				value := (1-wx)*(1-wy)*float64(srcRGBA.Pix[srcRGBA.PixOffset(x0, y0)+c]) +
+					wx*(1-wy)*float64(srcRGBA.Pix[srcRGBA.PixOffset(x1, y0)+c]) +
+					(1-wx)*wy*float64(srcRGBA.Pix[srcRGBA.PixOffset(x0, y1)+c]) +
+					wx*wy*float64(srcRGBA.Pix[srcRGBA.PixOffset(x1, y1)+c])
+				dst.Pix[dst.PixOffset(x, y)+c] = uint8(math.Round(value))
+			}
+		}
+	}
+
+	return dst
+}
+
+func clampInt(value, low, high int) int {
+	if value < low {
+		return low
+	} else if value > high {
+		return high
+	}
+	return value
+}
@@ -0,0 +1,216 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"watgbridge/state"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	htmlMetaTagRegex   = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	htmlAttributeRegex = regexp.MustCompile(`(?s)([a-zA-Z_:.-]+)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+	htmlTitleRegex     = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+)
+
+// Longest description which is kept in previews, WhatsApp only shows a couple of lines
+const linkPreviewMaxDescriptionLength = 300
+
+// LinkPreview is what WhatsApp shows under a text for the link in it
+type LinkPreview struct {
+	MatchedText  string
+	CanonicalUrl string
+	Title        string
+	Description  string
+	// Thumbnail is a JPEG made from the image of the page, which is empty if it has none
+	Thumbnail []byte
+}
+
+// LinkPreviewClient is the HTTP client used to fetch the pages of links and their images
+var LinkPreviewClient = &http.Client{}
+
+// LinkPreviewTimeout is the time given to make a preview, fetching both the page and
+// its image, set up by SetupLinkPreviews
+var LinkPreviewTimeout = 5 * time.Second
+
+// SetupLinkPreviews sets up the time given to link previews as configured
+func SetupLinkPreviews() {
+	cfg := state.State.Config
+
+	LinkPreviewTimeout = time.Duration(cfg.WhatsApp.LinkPreviews.TimeoutSeconds) * time.Second
+	if LinkPreviewTimeout <= 0 {
+		LinkPreviewTimeout = 5 * time.Second
+	}
+}
+
+// FetchLinkPreview fetches the page of the link and makes its preview out of its Open
+// Graph tags, falling back to its title and description. Only the first maxSize bytes
+// of the page are read, and its image is left out if it is larger than that or cannot
+// be fetched before the context is done.
+func FetchLinkPreview(ctx context.Context, client *http.Client, link, userAgent string, maxSize int64) (*LinkPreview, error) {
+	pageBody, pageURL, contentType, err := linkPreviewGet(ctx, client, link, userAgent, maxSize)
+	if err != nil {
+		return nil, err
+	}
+	if mediaType := BaseMimeType(contentType); mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, fmt.Errorf("link is not a web page but %s", mediaType)
+	}
+
+	page := strings.ToValidUTF8(string(pageBody), "")
+	tags := make(map[string]string)
+	for _, metaTag := range htmlMetaTagRegex.FindAllString(page, -1) {
+		var name, content string
+		for _, attribute := range htmlAttributeRegex.FindAllStringSubmatch(metaTag, -1) {
+			value := attribute[2] + attribute[3] + attribute[4]
+			switch strings.ToLower(attribute[1]) {
+			case "property", "name":
+				name = strings.ToLower(value)
+			case "content":
+				content = strings.TrimSpace(html.UnescapeString(value))
+			}
+		}
+		// The first of repeated tags is the one meant to be shown
+		if _, found := tags[name]; name != "" && content != "" && !found {
+			tags[name] = content
+		}
+	}
+
+	firstOf := func(names ...string) string {
+		for _, name := range names {
+			if value := tags[name]; value != "" {
+				return value
+			}
+		}
+		return ""
+	}
+
+	preview := &LinkPreview{
+		CanonicalUrl: pageURL.String(),
+		Title:        firstOf("og:title", "twitter:title"),
+		Description:  firstOf("og:description", "twitter:description", "description"),
+	}
+	if preview.Title == "" {
+		if match := htmlTitleRegex.FindStringSubmatch(page); match != nil {
+			preview.Title = strings.Join(strings.Fields(html.UnescapeString(match[1])), " ")
+		}
+	}
+	if preview.Title == "" {
+		return nil, errors.New("page has no title")
+	}
+	if len([]rune(preview.Description)) > linkPreviewMaxDescriptionLength {
+		preview.Description = SubString(preview.Description, 0, linkPreviewMaxDescriptionLength) + "..."
+	}
+	if canonicalURL, err := pageURL.Parse(firstOf("og:url")); err == nil && canonicalURL.Host != "" {
+		preview.CanonicalUrl = canonicalURL.String()
+	}
+
+	if imageURL, err := pageURL.Parse(firstOf("og:image", "og:image:url", "og:image:secure_url", "twitter:image")); err == nil && imageURL.Host != "" {
+		imageBody, _, _, err := linkPreviewGet(ctx, client, imageURL.String(), userAgent, maxSize)
+		if err == nil && int64(len(imageBody)) <= maxSize {
+			preview.Thumbnail, _, _, _ = MakeJpegThumbnail(imageBody)
+		}
+	}
+
+	return preview, nil
+}
+
+// linkPreviewGet reads up to one byte more than maxSize of the body at the URL, so that
+// callers can tell if it was larger, returning the URL it was fetched from after any
+// redirects and its content type
+func linkPreviewGet(ctx context.Context, client *http.Client, link, userAgent string, maxSize int64) ([]byte, *url.URL, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if userAgent != "" {
+		req.Header.Set("User-Agent", userAgent)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, nil, "", fmt.Errorf("unexpected status: %s", res.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxSize+1))
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return body, res.Request.URL, res.Header.Get("Content-Type"), nil
+}
+
+// TgGetLinkPreview makes the preview of the first link in the text, if link previews
+// are turned on and the page of the link has something to show
+func TgGetLinkPreview(entities []gotgbot.ParsedMessageEntity) *LinkPreview {
+	var (
+		cfg    = state.State.Config
+		logger = state.State.Logger
+	)
+	defer logger.Sync()
+
+	if !cfg.WhatsApp.LinkPreviews.Enabled {
+		return nil
+	}
+
+	for _, entity := range entities {
+		if entity.Type != "url" {
+			continue
+		}
+
+		link := entity.Text
+		if !strings.Contains(link, "://") {
+			link = "http://" + link
+		}
+		if parsedLink, err := url.Parse(link); err != nil || (parsedLink.Scheme != "http" && parsedLink.Scheme != "https") {
+			return nil
+		}
+
+		// A single deadline covers both the page and its image, so that the message is
+		// not held back for longer than configured
+		ctx, cancel := context.WithTimeout(context.Background(), LinkPreviewTimeout)
+		defer cancel()
+
+		preview, err := FetchLinkPreview(ctx, LinkPreviewClient, link, cfg.WhatsApp.LinkPreviews.UserAgent,
+			cfg.WhatsApp.LinkPreviews.MaxSizeKB*1024)
+		if err != nil {
+			logger.Debug("failed to make link preview",
+				zap.String("link", link),
+				zap.Error(err),
+			)
+			return nil
+		}
+		preview.MatchedText = entity.Text
+		return preview
+	}
+
+	return nil
+}
+
+// WaSetLinkPreview fills in the preview of the link in the text message
+func WaSetLinkPreview(msg *waProto.ExtendedTextMessage, preview *LinkPreview) {
+	msg.MatchedText = proto.String(preview.MatchedText)
+	msg.CanonicalUrl = proto.String(preview.CanonicalUrl)
+	msg.Title = proto.String(preview.Title)
+	msg.Description = proto.String(preview.Description)
+	msg.PreviewType = waProto.ExtendedTextMessage_NONE.Enum()
+	if len(preview.Thumbnail) > 0 {
+		msg.JpegThumbnail = preview.Thumbnail
+	}
+}
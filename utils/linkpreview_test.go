@@ -0,0 +1,221 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFetchLinkPreview(t *testing.T) {
+	var pngImage bytes.Buffer
+	if err := png.Encode(&pngImage, image.NewRGBA(image.Rect(0, 0, 400, 200))); err != nil {
+		t.Fatal(err)
+	}
+
+	pages := map[string]string{
+		"/og": `<html><head>
+			<title>Fallback title</title>
+			<meta property="og:title" content="Open Graph &amp; title">
+			<meta property="og:title" content="Second title">
+			<meta name="description" content="Fallback description">
+			<meta content='Open Graph description' property='og:description'>
+			<meta property="og:url" content="/canonical">
+			<meta property="og:image" content="images/preview.png">
+			</head></html>`,
+		"/title": `<html><head>
+			<title>
+				Only   a
+				title
+			</title>
+			<meta name="description" content="Plain description">
+			</head></html>`,
+		"/long": `<html><head>
+			<meta property="og:title" content="Long">
+			<meta property="og:description" content="` + strings.Repeat("é", 400) + `">
+			</head></html>`,
+		"/absolute": `<html><head>
+			<meta property="og:title" content="Absolute">
+			<meta property="og:url" content="https://example.com/canonical">
+			</head></html>`,
+		"/large-image": `<html><head>
+			<meta property="og:title" content="Large image">
+			<meta property="og:image" content="/large.png">
+			</head></html>`,
+		"/slow-image": `<html><head>
+			<meta property="og:title" content="Slow image">
+			<meta property="og:image" content="/slow.png">
+			</head></html>`,
+		"/truncated": `<html><head><!-- ` + strings.Repeat("x", 4096) + ` -->
+			<meta property="og:title" content="Too far">
+			</head></html>`,
+		"/untitled": `<html><head><meta name="description" content="No title"></head></html>`,
+	}
+
+	mux := http.NewServeMux()
+	for path, page := range pages {
+		page := page
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			// Pages fetched with another user agent fail the cases which expect a preview
+			if r.UserAgent() != "watgbridge-test" {
+				http.Error(w, "unexpected user agent", http.StatusForbidden)
+				return
+			}
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(page))
+		})
+	}
+	mux.HandleFunc("/images/preview.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(pngImage.Bytes())
+	})
+	mux.HandleFunc("/large.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(append(pngImage.Bytes(), make([]byte, 8192)...))
+	})
+	mux.HandleFunc("/slow.png", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	mux.HandleFunc("/image", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(pngImage.Bytes())
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/og", http.StatusFound)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tests := []struct {
+		name          string
+		path          string
+		maxSize       int64
+		timeout       time.Duration
+		wantErr       bool
+		wantTitle     string
+		wantDesc      string
+		wantCanonical string
+		wantThumbnail bool
+	}{
+		{
+			name:          "open graph tags",
+			path:          "/og",
+			wantTitle:     "Open Graph & title",
+			wantDesc:      "Open Graph description",
+			wantCanonical: server.URL + "/canonical",
+			wantThumbnail: true,
+		},
+		{
+			name:          "relative URLs resolved after redirect",
+			path:          "/redirect",
+			wantTitle:     "Open Graph & title",
+			wantDesc:      "Open Graph description",
+			wantCanonical: server.URL + "/canonical",
+			wantThumbnail: true,
+		},
+		{
+			name:          "title and description fallback",
+			path:          "/title",
+			wantTitle:     "Only a title",
+			wantDesc:      "Plain description",
+			wantCanonical: server.URL + "/title",
+		},
+		{
+			name:          "description cut",
+			path:          "/long",
+			wantTitle:     "Long",
+			wantDesc:      strings.Repeat("é", linkPreviewMaxDescriptionLength) + "...",
+			wantCanonical: server.URL + "/long",
+		},
+		{
+			name:          "absolute canonical URL",
+			path:          "/absolute",
+			wantTitle:     "Absolute",
+			wantCanonical: "https://example.com/canonical",
+		},
+		{
+			name:          "image larger than max size",
+			path:          "/large-image",
+			maxSize:       int64(pngImage.Len()) + 4096,
+			wantTitle:     "Large image",
+			wantCanonical: server.URL + "/large-image",
+		},
+		{
+			name:          "image not fetched before deadline",
+			path:          "/slow-image",
+			timeout:       200 * time.Millisecond,
+			wantTitle:     "Slow image",
+			wantCanonical: server.URL + "/slow-image",
+		},
+		{
+			name:    "tags after max size",
+			path:    "/truncated",
+			maxSize: 1024,
+			wantErr: true,
+		},
+		{
+			name:    "no title",
+			path:    "/untitled",
+			wantErr: true,
+		},
+		{
+			name:    "not a web page",
+			path:    "/image",
+			wantErr: true,
+		},
+		{
+			name:    "not found",
+			path:    "/missing",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			maxSize := test.maxSize
+			if maxSize == 0 {
+				maxSize = 1 << 20
+			}
+			timeout := test.timeout
+			if timeout == 0 {
+				timeout = 5 * time.Second
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			started := time.Now()
+			preview, err := FetchLinkPreview(ctx, server.Client(), server.URL+test.path, "watgbridge-test", maxSize)
+			if elapsed := time.Since(started); elapsed > timeout+time.Second {
+				t.Errorf("took %v, longer than the deadline of %v", elapsed, timeout)
+			}
+
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("got preview %+v, want error", preview)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if preview.Title != test.wantTitle {
+				t.Errorf("title = %q, want %q", preview.Title, test.wantTitle)
+			}
+			if preview.Description != test.wantDesc {
+				t.Errorf("description = %q, want %q", preview.Description, test.wantDesc)
+			}
+			if preview.CanonicalUrl != test.wantCanonical {
+				t.Errorf("canonical URL = %q, want %q", preview.CanonicalUrl, test.wantCanonical)
+			}
+			if hasThumbnail := len(preview.Thumbnail) > 0; hasThumbnail != test.wantThumbnail {
+				t.Errorf("has thumbnail = %v, want %v", hasThumbnail, test.wantThumbnail)
+			}
+		})
+	}
+}
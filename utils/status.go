@@ -0,0 +1,267 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"watgbridge/database"
+	"watgbridge/state"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"go.mau.fi/whatsmeow"
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	StatusLayoutPerContact  = "per_contact"
+	StatusLayoutSingleTopic = "single_topic"
+)
+
+// WaParseStatusColor parses a colour like #RRGGBB into the ARGB value used by WhatsApp
+func WaParseStatusColor(color string) (uint32, error) {
+	hex := strings.TrimPrefix(color, "#")
+	if len(hex) != 6 {
+		return 0, fmt.Errorf("colour '%s' is not of the form #RRGGBB", color)
+	}
+	rgb, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("colour '%s' is not of the form #RRGGBB", color)
+	}
+	return 0xFF000000 | uint32(rgb), nil
+}
+
+// WaParseStatusFont parses the name of a font like sans_serif or oswald_heavy
+func WaParseStatusFont(font string) (waProto.ExtendedTextMessage_FontType, error) {
+	value, found := waProto.ExtendedTextMessage_FontType_value[strings.ToUpper(font)]
+	if !found {
+		return 0, fmt.Errorf("unknown font '%s'", font)
+	}
+	return waProto.ExtendedTextMessage_FontType(value), nil
+}
+
+// statusAudienceStore is put in place of the contact store of the clients. whatsmeow
+// sends statuses to the contacts listed by the store, so while a status is posted to
+// an audience the store only lists the audience.
+type statusAudienceStore struct {
+	store.ContactStore
+
+	postLock sync.Mutex // Held while a status is posted to an audience
+	lock     sync.RWMutex
+	audience map[types.JID]types.ContactInfo
+}
+
+func (s *statusAudienceStore) GetAllContacts() (map[types.JID]types.ContactInfo, error) {
+	s.lock.RLock()
+	audience := s.audience
+	s.lock.RUnlock()
+
+	if audience != nil {
+		return audience, nil
+	}
+	return s.ContactStore.GetAllContacts()
+}
+
+func (s *statusAudienceStore) setAudience(audience map[types.JID]types.ContactInfo) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.audience = audience
+}
+
+// WaWrapContactStore puts the contact store of the client behind one which can limit
+// the audience of statuses. It does nothing if the client has no store yet, which is
+// the case till a new device is paired, or if it is already wrapped.
+func WaWrapContactStore(client *whatsmeow.Client) {
+	if client.Store.Contacts == nil {
+		return
+	}
+	if _, wrapped := client.Store.Contacts.(*statusAudienceStore); wrapped {
+		return
+	}
+	client.Store.Contacts = &statusAudienceStore{ContactStore: client.Store.Contacts}
+}
+
+// WaGetStatusAudience returns the contacts which statuses are posted to, as set in the
+// config file, or nothing if they are left to the status privacy settings of the phone
+func WaGetStatusAudience() ([]types.JID, error) {
+	var audience []types.JID
+	for _, entry := range state.State.Config.WhatsApp.StatusPosting.Audience {
+		if strings.TrimSpace(entry) == "" {
+			return nil, fmt.Errorf("empty entry in the status audience")
+		}
+		jid, ok := WaParseJID(strings.TrimSpace(entry))
+		if !ok || jid.Server != types.DefaultUserServer {
+			return nil, fmt.Errorf("'%s' in the status audience is not a phone number or the JID of a contact", entry)
+		}
+		audience = append(audience, jid)
+	}
+	return audience, nil
+}
+
+// waSendStatus posts the message as a status of the account, only to the audience from
+// the config file if one is set
+func waSendStatus(account *state.WhatsAppAccount, msg *waProto.Message) (whatsmeow.SendResponse, error) {
+	waClient := account.Client
+
+	audience, err := WaGetStatusAudience()
+	if err != nil {
+		return whatsmeow.SendResponse{}, err
+	} else if len(audience) == 0 {
+		return waClient.SendMessage(context.Background(), types.StatusBroadcastJID, msg)
+	}
+
+	contactStore, ok := waClient.Store.Contacts.(*statusAudienceStore)
+	if !ok {
+		return whatsmeow.SendResponse{}, fmt.Errorf("the status audience cannot be used before the account has logged in")
+	}
+
+	// whatsmeow sends to the contacts chosen on the phone rather than to the contact
+	// store in this mode, which would leave the audience out
+	statusPrivacy, err := waClient.GetStatusPrivacy()
+	if err != nil {
+		return whatsmeow.SendResponse{}, fmt.Errorf("failed to get the status privacy settings: %s", err)
+	} else if statusPrivacy[0].Type == types.StatusPrivacyTypeWhitelist {
+		return whatsmeow.SendResponse{}, fmt.Errorf("the status privacy of the phone is set to \"Only share with...\", " +
+			"which is used instead of the audience in the config file, set it to \"My contacts\" to post to the audience")
+	}
+
+	contacts := make(map[types.JID]types.ContactInfo, len(audience))
+	for _, jid := range audience {
+		contact, _ := contactStore.ContactStore.GetContact(jid)
+		if contact.FullName == "" {
+			// whatsmeow skips the contacts without names
+			contact.FullName = jid.User
+		}
+		contacts[jid] = contact
+	}
+
+	contactStore.postLock.Lock()
+	defer contactStore.postLock.Unlock()
+
+	contactStore.setAudience(contacts)
+	defer contactStore.setAudience(nil)
+
+	return waClient.SendMessage(context.Background(), types.StatusBroadcastJID, msg)
+}
+
+// WaPostTextStatus posts the text as a status of the account. Like the statuses posted
+// from the phone, it is sent to the contacts allowed by the status privacy settings,
+// or only to the audience from the config file if one is set.
+func WaPostTextStatus(account *state.WhatsAppAccount, text string, backgroundArgb uint32,
+	font waProto.ExtendedTextMessage_FontType) (whatsmeow.SendResponse, error) {
+
+	return waSendStatus(account, &waProto.Message{
+		ExtendedTextMessage: &waProto.ExtendedTextMessage{
+			Text:           proto.String(text),
+			TextArgb:       proto.Uint32(0xFFFFFFFF),
+			BackgroundArgb: proto.Uint32(backgroundArgb),
+			Font:           font.Enum(),
+		},
+	})
+}
+
+// TgGetOrMakeStatusHeader returns the status topic and the header message of the
+// contact for today in it, which the statuses are sent as replies to. The header is
+// sent with a button to mark the statuses as seen.
+func TgGetOrMakeStatusHeader(account *state.WhatsAppAccount, sender types.JID, tgChatId int64) (int64, int64, error) {
+	var (
+		tgBot    = state.State.TelegramBot
+		senderId = sender.ToNonAD().String()
+		day      = time.Now().In(state.State.LocalLocation).Format("2006-01-02")
+	)
+
+	threadId, err := TgGetOrMakeSystemThread(account, SystemTopicStatus, tgChatId)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	header, err := database.StatusHeaderGet(account.Name, senderId, day, tgChatId, threadId)
+	if err != nil {
+		return 0, 0, err
+	} else if header.ID != 0 && header.TgMsgId != 0 {
+		return threadId, header.TgMsgId, nil
+	} else if header.ID != 0 {
+		// Left behind by a header which failed to be sent, it is made again
+		if err = database.StatusHeaderDelete(header.ID); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	// The row is added first as the button of the header needs its ID, and deleted
+	// again if the header cannot be sent so that later statuses do not reply to nothing
+	header = database.StatusHeader{
+		Account:    account.Name,
+		SenderId:   senderId,
+		Day:        day,
+		TgChatId:   tgChatId,
+		TgThreadId: threadId,
+	}
+	if err = database.StatusHeaderAdd(&header); err != nil {
+		return 0, 0, err
+	}
+
+	headerMsg, err := tgBot.SendMessage(tgChatId,
+		fmt.Sprintf("<b>#Status</b> of <b>%s</b>\n%s", html.EscapeString(WaGetContactName(sender)), day),
+		&gotgbot.SendMessageOpts{
+			MessageThreadId: threadId,
+			ReplyMarkup: gotgbot.InlineKeyboardMarkup{
+				InlineKeyboard: [][]gotgbot.InlineKeyboardButton{{{
+					Text:         "Mark seen",
+					CallbackData: fmt.Sprintf("statusseen_%v", header.ID),
+				}}},
+			},
+		})
+	if err != nil {
+		database.StatusHeaderDelete(header.ID)
+		return 0, 0, err
+	}
+
+	header.TgMsgId = headerMsg.MessageId
+	if err = database.StatusHeaderSetMsgId(header.ID, header.TgMsgId); err != nil {
+		database.StatusHeaderDelete(header.ID)
+		return 0, 0, err
+	}
+	return threadId, header.TgMsgId, nil
+}
+
+// WaMarkStatusesSeen sends view receipts for the statuses sent as replies to the
+// header, returning how many there were
+func WaMarkStatusesSeen(account *state.WhatsAppAccount, headerId uint) (int, error) {
+	header, err := database.StatusHeaderGetById(headerId)
+	if err != nil {
+		return 0, err
+	} else if header.ID == 0 || header.Account != account.Name {
+		return 0, fmt.Errorf("status header not found")
+	}
+
+	dayStart, err := time.ParseInLocation("2006-01-02", header.Day, state.State.LocalLocation)
+	if err != nil {
+		return 0, err
+	}
+	statuses, err := database.MsgIdGetStatusesInRange(account.Name, header.TgChatId, header.TgThreadId,
+		dayStart.Local(), dayStart.AddDate(0, 0, 1).Local())
+	if err != nil {
+		return 0, err
+	}
+
+	sender, _ := WaParseJID(header.SenderId)
+	var statusIds []types.MessageID
+	for _, status := range statuses {
+		if participant, _ := WaParseJID(status.ParticipantId); participant.User == sender.User {
+			statusIds = append(statusIds, status.ID)
+		}
+	}
+	if len(statusIds) == 0 {
+		return 0, nil
+	}
+
+	return len(statusIds), account.Client.MarkRead(statusIds, time.Now(), types.StatusBroadcastJID, sender)
+}
@@ -0,0 +1,210 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"watgbridge/database"
+	"watgbridge/state"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"go.mau.fi/whatsmeow"
+	"go.uber.org/zap"
+)
+
+// WhatsApp keeps uploaded media for a limited time, older uploads are made again
+const stickerCacheUploadMaxAge = 7 * 24 * time.Hour
+
+var stickerCacheLock sync.Mutex
+
+// StickerCacheKeyTg returns the key of the converted output of a Telegram sticker
+func StickerCacheKeyTg(fileUniqueId, output string) string {
+	return "tg:" + fileUniqueId + ":" + output
+}
+
+// StickerCacheKeyWa returns the key of the converted output of a WhatsApp sticker
+func StickerCacheKeyWa(fileSha256 []byte, output string) string {
+	return "wa:" + hex.EncodeToString(fileSha256) + ":" + output
+}
+
+func stickerCacheEnabled() bool {
+	return state.State.Config.StickerCache.MaxSizeMB > 0
+}
+
+// StickerCacheGet returns the cached sticker and its entry, marking it as used. Nothing
+// is returned if the cache is turned off or the sticker is not in it.
+func StickerCacheGet(key string) ([]byte, *database.StickerCacheEntry) {
+	if !stickerCacheEnabled() {
+		return nil, nil
+	}
+
+	stickerCacheLock.Lock()
+	defer stickerCacheLock.Unlock()
+
+	entry, err := database.StickerCacheGet(key)
+	if err != nil || entry.ID != key {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path.Join(state.State.Config.StickerCache.Path, entry.FileName))
+	if err != nil {
+		database.StickerCacheDelete(key)
+		return nil, nil
+	}
+
+	entry.LastUsed = time.Now().UTC()
+	database.StickerCacheSave(&entry)
+	return data, &entry
+}
+
+// StickerCachePut stores the converted sticker, removing the least recently used ones
+// if the cache grows beyond its size limit
+func StickerCachePut(key string, data []byte) error {
+	if !stickerCacheEnabled() {
+		return nil
+	}
+
+	stickerCacheLock.Lock()
+	defer stickerCacheLock.Unlock()
+
+	cachePath := state.State.Config.StickerCache.Path
+	if err := os.MkdirAll(cachePath, os.ModePerm); err != nil {
+		return err
+	}
+
+	fileName := fmt.Sprintf("%x", sha256.Sum256([]byte(key)))
+	if err := os.WriteFile(path.Join(cachePath, fileName), data, 0644); err != nil {
+		return err
+	}
+
+	err := database.StickerCacheSave(&database.StickerCacheEntry{
+		ID:       key,
+		FileName: fileName,
+		Size:     int64(len(data)),
+		LastUsed: time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	return stickerCacheEvict()
+}
+
+func stickerCacheEvict() error {
+	var (
+		cfg     = state.State.Config
+		maxSize = cfg.StickerCache.MaxSizeMB * 1024 * 1024
+	)
+
+	entries, err := database.StickerCacheGetAll()
+	if err != nil {
+		return err
+	}
+
+	var totalSize int64
+	for _, entry := range entries {
+		totalSize += entry.Size
+	}
+
+	for _, entry := range entries {
+		if totalSize <= maxSize {
+			break
+		}
+		os.Remove(path.Join(cfg.StickerCache.Path, entry.FileName))
+		if err := database.StickerCacheDelete(entry.ID); err != nil {
+			return err
+		}
+		totalSize -= entry.Size
+	}
+
+	return nil
+}
+
+// StickerCacheGetWaUpload returns the WhatsApp upload of the cached sticker, if it is
+// recent enough to be sent again
+func StickerCacheGetWaUpload(entry *database.StickerCacheEntry) (whatsmeow.UploadResponse, bool) {
+	if entry == nil || entry.WaUrl == "" || time.Since(entry.WaUploadedAt) > stickerCacheUploadMaxAge {
+		return whatsmeow.UploadResponse{}, false
+	}
+	return whatsmeow.UploadResponse{
+		URL:           entry.WaUrl,
+		DirectPath:    entry.WaDirectPath,
+		MediaKey:      entry.WaMediaKey,
+		FileEncSHA256: entry.WaFileEncSha256,
+		FileSHA256:    entry.WaFileSha256,
+	}, true
+}
+
+// StickerCacheSetWaUpload keeps the WhatsApp upload of the cached sticker
+func StickerCacheSetWaUpload(key string, uploaded whatsmeow.UploadResponse) error {
+	return stickerCacheUpdate(key, func(entry *database.StickerCacheEntry) {
+		entry.WaUrl = uploaded.URL
+		entry.WaDirectPath = uploaded.DirectPath
+		entry.WaMediaKey = uploaded.MediaKey
+		entry.WaFileEncSha256 = uploaded.FileEncSHA256
+		entry.WaFileSha256 = uploaded.FileSHA256
+		entry.WaUploadedAt = time.Now().UTC()
+	})
+}
+
+// StickerCacheSetTgFileId keeps the Telegram file ID of the cached sticker
+func StickerCacheSetTgFileId(key, fileId string) error {
+	return stickerCacheUpdate(key, func(entry *database.StickerCacheEntry) {
+		entry.TgFileId = fileId
+	})
+}
+
+func stickerCacheUpdate(key string, update func(*database.StickerCacheEntry)) error {
+	if !stickerCacheEnabled() {
+		return nil
+	}
+
+	stickerCacheLock.Lock()
+	defer stickerCacheLock.Unlock()
+
+	entry, err := database.StickerCacheGet(key)
+	if err != nil || entry.ID != key {
+		return err
+	}
+	update(&entry)
+	return database.StickerCacheSave(&entry)
+}
+
+// WaGetAnimatedStickerForTg returns the video sticker to send for an animated WhatsApp
+// sticker, which is its Telegram file ID if it has been sent before, along with the key
+// under which it is cached
+func WaGetAnimatedStickerForTg(stickerBytes, fileSha256 []byte) (gotgbot.InputFile, string, error) {
+	logger := state.State.Logger
+	defer logger.Sync()
+
+	cacheKey := StickerCacheKeyWa(fileSha256, "webm")
+	webmBytes, cacheEntry := StickerCacheGet(cacheKey)
+	if cacheEntry != nil && cacheEntry.TgFileId != "" {
+		return cacheEntry.TgFileId, cacheKey, nil
+	}
+
+	if webmBytes == nil {
+		var err error
+		webmBytes, err = AnimatedWebpConvertToWebm(stickerBytes)
+		if err != nil {
+			return nil, cacheKey, err
+		}
+		if err = StickerCachePut(cacheKey, webmBytes); err != nil {
+			logger.Warn("failed to cache converted sticker",
+				zap.String("key", cacheKey),
+				zap.Error(err),
+			)
+		}
+	}
+
+	return gotgbot.NamedFile{
+		FileName: "sticker.webm",
+		File:     bytes.NewReader(webmBytes),
+	}, cacheKey, nil
+}
@@ -11,7 +11,6 @@ import (
 	"os"
 	"os/exec"
 	"path"
-	"strconv"
 
 	"watgbridge/state"
 
@@ -43,7 +42,7 @@ func TGSConvertToWebp(tgsStickerData []byte, updateId int64) ([]byte, error) {
 		if err != nil {
 			return nil, err
 		} else if len(webpStickerData) < 1024*1024 {
-			if outputDataWithExif, err := WebpWriteExifData(webpStickerData, updateId); err == nil {
+			if outputDataWithExif, err := WebpWriteExifData(webpStickerData); err == nil {
 				return outputDataWithExif, nil
 			}
 			return webpStickerData, nil
@@ -54,48 +53,22 @@ func TGSConvertToWebp(tgsStickerData []byte, updateId int64) ([]byte, error) {
 	return nil, fmt.Errorf("sticker has a lot of data which cannot be handled by WhatsApp")
 }
 
-func WebmConvertToWebp(webmStickerData []byte, scale, pad string, updateId int64) ([]byte, error) {
-
-	var (
-		currTime   = strconv.FormatInt(updateId, 10)
-		currPath   = path.Join("downloads", currTime)
-		inputPath  = path.Join(currPath, "input.webm")
-		outputPath = path.Join(currPath, "output.webp")
-	)
-
-	if err := os.MkdirAll(currPath, os.ModePerm); err != nil {
-		return nil, err
-	}
-	defer os.RemoveAll(currPath)
-
-	if err := os.WriteFile(inputPath, webmStickerData, os.ModePerm); err != nil {
-		return nil, err
-	}
-
-	cmd := exec.Command(state.State.Config.FfmpegExecutable,
-		"-i", inputPath,
-		"-fs", "800000",
-		"-vf", fmt.Sprintf("fps=15,scale=%s,format=rgba,pad=%s:color=#00000000", scale, pad),
-		outputPath,
-	)
-
-	if err := cmd.Run(); err != nil {
-		return nil, fmt.Errorf("failed to execute ffmpeg command: %s", err)
-	}
-
-	outputData, err := os.ReadFile(outputPath)
+// WebmConvertToWebp converts a video sticker, or a static image like a custom emoji,
+// into a 512x512 WhatsApp sticker
+func WebmConvertToWebp(webmStickerData []byte) ([]byte, error) {
+	outputData, err := Converter.StickerToWebp(webmStickerData)
 	if err != nil {
 		return nil, err
 	}
 
-	if outputDataWithExif, err := WebpWriteExifData(outputData, updateId); err == nil {
+	if outputDataWithExif, err := WebpWriteExifData(outputData); err == nil {
 		return outputDataWithExif, nil
 	}
 
 	return outputData, nil
 }
 
-func WebpImagePad(inputData []byte, wPad, hPad int, updateId int64) ([]byte, error) {
+func WebpImagePad(inputData []byte, wPad, hPad int) ([]byte, error) {
 	webpDecoder, err := decoder.NewDecoder(bytes.NewBuffer(inputData), &decoder.Options{NoFancyUpsampling: true})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create a webp decoder: %s", err)
@@ -127,62 +100,29 @@ func WebpImagePad(inputData []byte, wPad, hPad int, updateId int64) ([]byte, err
 		return nil, fmt.Errorf("failed to encode into webp: %s", err)
 	}
 
-	if outputData, err := WebpWriteExifData(outputBuffer.Bytes(), updateId); err == nil {
+	if outputData, err := WebpWriteExifData(outputBuffer.Bytes()); err == nil {
 		return outputData, nil
 	}
 
 	return outputBuffer.Bytes(), nil
 }
 
-func AnimatedWebpConvertToGif(inputData []byte, updateId string) ([]byte, error) {
-	var (
-		logger = state.State.Logger
-
-		currPath   = path.Join("downloads", updateId)
-		inputPath  = path.Join(currPath, "input.webp")
-		outputPath = path.Join(currPath, "output.gif")
-	)
-	defer logger.Sync()
-
-	if err := os.MkdirAll(currPath, os.ModePerm); err != nil {
-		return nil, err
-	}
-	defer os.RemoveAll(currPath)
-
-	if err := os.WriteFile(inputPath, inputData, os.ModePerm); err != nil {
-		return nil, err
-	}
-
-	cmd := exec.Command("convert",
-		inputPath,
-		"-loop", "0",
-		"-dispose", "previous",
-		outputPath,
-	)
-
-	if err := cmd.Run(); err != nil {
-		logger.Debug("failed to run convert command",
-			zap.Error(err),
-		)
-		return nil, err
-	}
+func AnimatedWebpConvertToGif(inputData []byte) ([]byte, error) {
+	return Converter.AnimatedWebpToGif(inputData)
+}
 
-	return os.ReadFile(outputPath)
+// AnimatedWebpConvertToWebm converts an animated WhatsApp sticker into a Telegram video sticker
+func AnimatedWebpConvertToWebm(inputData []byte) ([]byte, error) {
+	return Converter.AnimatedWebpToWebm(inputData)
 }
 
-func WebpWriteExifData(inputData []byte, updateId int64) ([]byte, error) {
+func WebpWriteExifData(inputData []byte) ([]byte, error) {
 	var (
 		cfg           = state.State.Config
 		logger        = state.State.Logger
 		startingBytes = []byte{0x49, 0x49, 0x2A, 0x00, 0x08, 0x00, 0x00, 0x00, 0x01, 0x00, 0x41, 0x57, 0x07, 0x00}
 		endingBytes   = []byte{0x16, 0x00, 0x00, 0x00}
 		b             bytes.Buffer
-
-		currUpdateId = strconv.FormatInt(updateId, 10)
-		currPath     = path.Join("downloads", currUpdateId)
-		inputPath    = path.Join(currPath, "input_exif.webm")
-		outputPath   = path.Join(currPath, "output_exif.webp")
-		exifDataPath = path.Join(currPath, "raw.exif")
 	)
 	defer logger.Sync()
 
@@ -215,15 +155,22 @@ func WebpWriteExifData(inputData []byte, updateId int64) ([]byte, error) {
 		return nil, err
 	}
 
-	if err := os.MkdirAll(currPath, os.ModePerm); err != nil {
+	currPath, err := makeTempDir()
+	if err != nil {
 		return nil, err
 	}
 	defer os.RemoveAll(currPath)
 
-	if err := os.WriteFile(inputPath, inputData, os.ModePerm); err != nil {
+	var (
+		inputPath    = path.Join(currPath, "input_exif.webp")
+		outputPath   = path.Join(currPath, "output_exif.webp")
+		exifDataPath = path.Join(currPath, "raw.exif")
+	)
+
+	if err := os.WriteFile(inputPath, inputData, 0644); err != nil {
 		return nil, err
 	}
-	if err := os.WriteFile(exifDataPath, b.Bytes(), os.ModePerm); err != nil {
+	if err := os.WriteFile(exifDataPath, b.Bytes(), 0644); err != nil {
 		return nil, err
 	}
 
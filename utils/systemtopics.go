@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"strings"
+
+	"watgbridge/state"
+)
+
+// Keys under which the system topics are stored in place of WhatsApp chat JIDs. The
+// status topic keeps the key used before the topics were split, so that it is reused.
+const (
+	SystemTopicStatus      = "status@broadcast"
+	SystemTopicCalls       = "calls@watgbridge"
+	SystemTopicMentions    = "mentions@watgbridge"
+	SystemTopicTagAlls     = "tagalls@watgbridge"
+	SystemTopicRevocations = "revocations@watgbridge"
+	SystemTopicErrors      = "errors@watgbridge"
+	SystemTopicAlerts      = "alerts@watgbridge"
+)
+
+// SystemTopics maps the names used in the /recreatetopic command to the keys of the topics
+var SystemTopics = map[string]string{
+	"status":      SystemTopicStatus,
+	"calls":       SystemTopicCalls,
+	"mentions":    SystemTopicMentions,
+	"tagalls":     SystemTopicTagAlls,
+	"revocations": SystemTopicRevocations,
+	"errors":      SystemTopicErrors,
+	"alerts":      SystemTopicAlerts,
+}
+
+// IsSystemTopic reports whether the key of a topic is that of a system topic
+// rather than a WhatsApp chat
+func IsSystemTopic(key string) bool {
+	return key == SystemTopicStatus || strings.HasSuffix(key, "@watgbridge")
+}
+
+// SystemTopicName returns the name of the system topic from the config file
+func SystemTopicName(key string) string {
+	names := state.State.Config.Telegram.SystemTopics
+	switch key {
+	case SystemTopicStatus:
+		return names.Status
+	case SystemTopicCalls:
+		return names.Calls
+	case SystemTopicMentions:
+		return names.Mentions
+	case SystemTopicTagAlls:
+		return names.TagAlls
+	case SystemTopicRevocations:
+		return names.Revocations
+	case SystemTopicErrors:
+		return names.Errors
+	case SystemTopicAlerts:
+		return names.Alerts
+	}
+	return key
+}
+
+// TgGetOrMakeSystemThread returns the system topic with the given key in the chat,
+// creating it with the name from the config file if it does not exist
+func TgGetOrMakeSystemThread(account *state.WhatsAppAccount, key string, tgChatId int64) (int64, error) {
+	return TgGetOrMakeThreadFromWa(account, key, tgChatId, SystemTopicName(key))
+}
+
+// TgRemakeSystemThread creates the system topic again, for when it has been deleted
+func TgRemakeSystemThread(account *state.WhatsAppAccount, key string, tgChatId int64) (int64, error) {
+	threadCreationLock.Lock()
+	defer threadCreationLock.Unlock()
+
+	return tgMakeThreadForWa(account, key, tgChatId, SystemTopicName(key))
+}
+
+// TgSendBridgeError sends the error to the bridge errors topic of the chat, or to
+// its general topic if that cannot be done
+func TgSendBridgeError(account *state.WhatsAppAccount, tgChatId int64, eMessage string, e error) error {
+	threadId, err := TgGetOrMakeSystemThread(account, SystemTopicErrors, tgChatId)
+	if err != nil {
+		threadId = 0
+	}
+	return TgSendErrorById(state.State.TelegramBot, tgChatId, threadId, eMessage, e)
+}
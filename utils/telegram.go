@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"sync"
 	"time"
 	"unicode"
 
@@ -20,6 +21,7 @@ import (
 	"go.mau.fi/whatsmeow"
 	waProto "go.mau.fi/whatsmeow/binary/proto"
 	waTypes "go.mau.fi/whatsmeow/types"
+	"go.uber.org/zap"
 	"golang.org/x/exp/slices"
 	"google.golang.org/protobuf/proto"
 )
@@ -37,26 +39,46 @@ func TgRegisterBotCommands(b *gotgbot.Bot, commands ...gotgbot.BotCommand) error
 	return err
 }
 
-func TgGetOrMakeThreadFromWa(waChatId string, tgChatId int64, threadName string) (int64, error) {
-	threadId, threadFound, err := database.ChatThreadGetTgFromWa(waChatId, tgChatId)
+// Held while topics are made, so that events of the same chat arriving together do not
+// make a topic each
+var threadCreationLock sync.Mutex
+
+func TgGetOrMakeThreadFromWa(account *state.WhatsAppAccount, waChatId string, tgChatId int64, threadName string) (int64, error) {
+	threadId, threadFound, err := database.ChatThreadGetTgFromWa(account.Name, waChatId, tgChatId)
 	if err != nil {
 		return 0, err
+	} else if threadFound {
+		return threadId, nil
 	}
 
-	if !threadFound {
-		tgBot := state.State.TelegramBot
-		newForum, err := tgBot.CreateForumTopic(tgChatId, threadName, &gotgbot.CreateForumTopicOpts{})
-		if err != nil {
-			return 0, err
-		}
-		err = database.ChatThreadAddNewPair(waChatId, tgChatId, newForum.MessageThreadId)
-		if err != nil {
-			return newForum.MessageThreadId, err
-		}
-		return newForum.MessageThreadId, nil
+	threadCreationLock.Lock()
+	defer threadCreationLock.Unlock()
+
+	threadId, threadFound, err = database.ChatThreadGetTgFromWa(account.Name, waChatId, tgChatId)
+	if err != nil {
+		return 0, err
+	} else if threadFound {
+		return threadId, nil
 	}
+	return tgMakeThreadForWa(account, waChatId, tgChatId, threadName)
+}
+
+// tgMakeThreadForWa creates a topic for the WhatsApp chat and saves it, deleting the
+// topic again if it cannot be saved so that it is not left without a chat
+func tgMakeThreadForWa(account *state.WhatsAppAccount, waChatId string, tgChatId int64, threadName string) (int64, error) {
+	tgBot := state.State.TelegramBot
 
-	return threadId, nil
+	newForum, err := tgBot.CreateForumTopic(tgChatId, threadName, &gotgbot.CreateForumTopicOpts{})
+	if err != nil {
+		return 0, err
+	}
+
+	err = database.ChatThreadAddNewPair(account.Name, waChatId, tgChatId, newForum.MessageThreadId)
+	if err != nil {
+		tgBot.DeleteForumTopic(tgChatId, newForum.MessageThreadId, &gotgbot.DeleteForumTopicOpts{})
+		return 0, err
+	}
+	return newForum.MessageThreadId, nil
 }
 
 func TgDownloadByFilePath(b *gotgbot.Bot, filePath string) ([]byte, error) {
@@ -115,7 +137,13 @@ func TgUpdateIsAuthorized(b *gotgbot.Bot, c *ext.Context) bool {
 		sudoUsersID = cfg.Telegram.SudoUsersID
 	)
 
-	if sender != nil &&
+	// Group chats other than the ones bridged into are not served
+	isServedChat := c.EffectiveChat == nil || c.EffectiveChat.Type == "private"
+	if !isServedChat {
+		_, isServedChat = state.State.WhatsAppAccountByTgChat(c.EffectiveChat.Id)
+	}
+
+	if sender != nil && isServedChat &&
 		(slices.Contains(sudoUsersID, sender.Id) || sender.Id == ownerID) {
 		return true
 	}
@@ -163,14 +191,14 @@ func TgSendErrorById(b *gotgbot.Bot, chatId, threadId int64, eMessage string, e
 	return err
 }
 
-func TgSendToWhatsApp(b *gotgbot.Bot, c *ext.Context,
+func TgSendToWhatsApp(b *gotgbot.Bot, c *ext.Context, account *state.WhatsAppAccount,
 	msgToForward, msgToReplyTo *gotgbot.Message,
 	waChatJID waTypes.JID, participant, stanzaId string,
 	isReply bool) error {
 
 	var (
 		cfg      = state.State.Config
-		waClient = state.State.WhatsAppClient
+		waClient = account.Client
 		mentions = []string{}
 	)
 
@@ -196,6 +224,22 @@ func TgSendToWhatsApp(b *gotgbot.Bot, c *ext.Context,
 		}
 	}
 
+	// A lone custom emoji is sent as a sticker, while in other texts they are replaced by their
+	// emojis. The message is copied so that the update is left as it was.
+	sticker := msgToForward.Sticker
+	if sticker == nil {
+		sticker = TgGetLoneCustomEmoji(b, msgToForward)
+	}
+	if sticker == nil {
+		msgCopy := *msgToForward
+		if len(msgToForward.Entities) > 0 {
+			msgCopy.Text = TgReplaceCustomEmojis(b, msgToForward.Text, entities)
+		} else if len(msgToForward.CaptionEntities) > 0 {
+			msgCopy.Caption = TgReplaceCustomEmojis(b, msgToForward.Caption, entities)
+		}
+		msgToForward = &msgCopy
+	}
+
 	if msgToForward.Photo != nil && len(msgToForward.Photo) > 0 {
 
 		bestPhoto := msgToForward.Photo[0]
@@ -229,6 +273,15 @@ func TgSendToWhatsApp(b *gotgbot.Bot, c *ext.Context,
 			return TgReplyWithErrorByContext(b, c, "Failed to upload image to WhatsApp", err)
 		}
 
+		// The smallest size of a photo is the thumbnail Telegram made of it
+		var imageThumbnail []byte
+		if len(msgToForward.Photo) > 1 {
+			imageThumbnail = TgGetThumbnail(b, &msgToForward.Photo[0])
+		}
+		if imageThumbnail == nil {
+			imageThumbnail, _, _, _ = MakeJpegThumbnail(imageBytes)
+		}
+
 		msgToSend := &waProto.Message{
 			ImageMessage: &waProto.ImageMessage{
 				Caption:           proto.String(msgToForward.Caption),
@@ -236,13 +289,14 @@ func TgSendToWhatsApp(b *gotgbot.Bot, c *ext.Context,
 				DirectPath:        proto.String(uploadedImage.DirectPath),
 				MediaKey:          uploadedImage.MediaKey,
 				MediaKeyTimestamp: proto.Int64(time.Now().Unix()),
-				Mimetype:          proto.String(http.DetectContentType(imageBytes)),
+				Mimetype:          proto.String(DetectMimeType(imageBytes, "")),
 				FileEncSha256:     uploadedImage.FileEncSHA256,
 				FileSha256:        uploadedImage.FileSHA256,
 				FileLength:        proto.Uint64(uint64(len(imageBytes))),
 				ViewOnce:          proto.Bool(msgToForward.HasProtectedContent),
 				Height:            proto.Uint32(uint32(bestPhoto.Height)),
 				Width:             proto.Uint32(uint32(bestPhoto.Width)),
+				JpegThumbnail:     imageThumbnail,
 				ContextInfo:       &waProto.ContextInfo{},
 			},
 		}
@@ -255,7 +309,7 @@ func TgSendToWhatsApp(b *gotgbot.Bot, c *ext.Context,
 			msgToSend.ImageMessage.ContextInfo.MentionedJid = mentions
 		}
 
-		sentMsg, err := waClient.SendMessage(context.Background(), waChatJID, msgToSend)
+		sentMsg, err := WaSendMessage(account, waChatJID, msgToSend)
 		if err != nil {
 			return TgReplyWithErrorByContext(b, c, "Failed to send image to WhatsApp", err)
 		}
@@ -268,8 +322,8 @@ func TgSendToWhatsApp(b *gotgbot.Bot, c *ext.Context,
 			}(b, msg)
 		}
 
-		err = database.MsgIdAddNewPair(sentMsg.ID, waClient.Store.ID.String(), waChatJID.String(),
-			cfg.Telegram.TargetChatID, msgToForward.MessageId, msgToForward.MessageThreadId)
+		err = database.MsgIdAddNewPair(account.Name, sentMsg.ID, waClient.Store.ID.String(), waChatJID.String(),
+			msgToForward.Chat.Id, msgToForward.MessageId, msgToForward.MessageThreadId)
 		if err != nil {
 			return TgReplyWithErrorByContext(b, c, "Failed to add to database", err)
 		}
@@ -300,21 +354,25 @@ func TgSendToWhatsApp(b *gotgbot.Bot, c *ext.Context,
 			return TgReplyWithErrorByContext(b, c, "Failed to upload video to WhatsApp", err)
 		}
 
+		videoWidth, videoHeight, videoSeconds, videoThumbnail := TgGetVideoDetails(b, videoBytes,
+			msgToForward.Video.Width, msgToForward.Video.Height, msgToForward.Video.Duration, msgToForward.Video.Thumbnail)
+
 		msgToSend := &waProto.Message{
 			VideoMessage: &waProto.VideoMessage{
 				Caption:       proto.String(msgToForward.Caption),
 				Url:           proto.String(uploadedVideo.URL),
 				DirectPath:    proto.String(uploadedVideo.DirectPath),
 				MediaKey:      uploadedVideo.MediaKey,
-				Mimetype:      proto.String(msgToForward.Video.MimeType),
+				Mimetype:      proto.String(DetectMimeType(videoBytes, msgToForward.Video.MimeType)),
 				FileEncSha256: uploadedVideo.FileEncSHA256,
 				FileSha256:    uploadedVideo.FileSHA256,
 				FileLength:    proto.Uint64(uint64(len(videoBytes))),
 				ViewOnce:      proto.Bool(msgToForward.HasProtectedContent),
-				Seconds:       proto.Uint32(uint32(msgToForward.Video.Duration)),
+				Seconds:       proto.Uint32(videoSeconds),
 				GifPlayback:   proto.Bool(false),
-				Height:        proto.Uint32(uint32(msgToForward.Video.Height)),
-				Width:         proto.Uint32(uint32(msgToForward.Video.Width)),
+				Height:        proto.Uint32(videoHeight),
+				Width:         proto.Uint32(videoWidth),
+				JpegThumbnail: videoThumbnail,
 				ContextInfo:   &waProto.ContextInfo{},
 			},
 		}
@@ -327,7 +385,7 @@ func TgSendToWhatsApp(b *gotgbot.Bot, c *ext.Context,
 			msgToSend.VideoMessage.ContextInfo.MentionedJid = mentions
 		}
 
-		sentMsg, err := waClient.SendMessage(context.Background(), waChatJID, msgToSend)
+		sentMsg, err := WaSendMessage(account, waChatJID, msgToSend)
 		if err != nil {
 			return TgReplyWithErrorByContext(b, c, "Failed to send video to WhatsApp", err)
 		}
@@ -340,8 +398,8 @@ func TgSendToWhatsApp(b *gotgbot.Bot, c *ext.Context,
 			}(b, msg)
 		}
 
-		err = database.MsgIdAddNewPair(sentMsg.ID, waClient.Store.ID.String(), waChatJID.String(),
-			cfg.Telegram.TargetChatID, msgToForward.MessageId, msgToForward.MessageThreadId)
+		err = database.MsgIdAddNewPair(account.Name, sentMsg.ID, waClient.Store.ID.String(), waChatJID.String(),
+			msgToForward.Chat.Id, msgToForward.MessageId, msgToForward.MessageThreadId)
 		if err != nil {
 			return TgReplyWithErrorByContext(b, c, "Failed to add to database", err)
 		}
@@ -366,37 +424,59 @@ func TgSendToWhatsApp(b *gotgbot.Bot, c *ext.Context,
 			return TgReplyWithErrorByContext(b, c, "Failed to download video note from Telegram", err)
 		}
 
+		videoBytes = ConvertVideoNote(videoBytes)
+		if len(videoBytes) > VideoNoteSizeLimit {
+			_, err := TgReplyTextByContext(b, c, "Unable to send video note as it exceeds WhatsApp size restriction", nil)
+			return err
+		}
+
+		var (
+			videoSeconds = msgToForward.VideoNote.Duration
+			videoLength  = msgToForward.VideoNote.Length
+		)
+		if videoSeconds > VideoNoteMaxSeconds {
+			videoSeconds = VideoNoteMaxSeconds
+		}
+		if videoLength > VideoNoteMaxLength {
+			videoLength = VideoNoteMaxLength
+		}
+
 		uploadedVideo, err := waClient.Upload(context.Background(), videoBytes, whatsmeow.MediaVideo)
 		if err != nil {
 			return TgReplyWithErrorByContext(b, c, "Failed to upload video note to WhatsApp", err)
 		}
 
+		_, _, _, videoThumbnail := TgGetVideoDetails(b, videoBytes,
+			videoLength, videoLength, videoSeconds, msgToForward.VideoNote.Thumbnail)
+
 		msgToSend := &waProto.Message{
-			VideoMessage: &waProto.VideoMessage{
+			PtvMessage: &waProto.VideoMessage{
 				Caption:       proto.String(msgToForward.Caption),
 				Url:           proto.String(uploadedVideo.URL),
 				DirectPath:    proto.String(uploadedVideo.DirectPath),
 				MediaKey:      uploadedVideo.MediaKey,
-				Mimetype:      proto.String(http.DetectContentType(videoBytes)),
+				Mimetype:      proto.String(DetectMimeType(videoBytes, "")),
 				FileEncSha256: uploadedVideo.FileEncSHA256,
 				FileSha256:    uploadedVideo.FileSHA256,
 				FileLength:    proto.Uint64(uint64(len(videoBytes))),
 				ViewOnce:      proto.Bool(msgToForward.HasProtectedContent),
-				Seconds:       proto.Uint32(uint32(msgToForward.VideoNote.Duration)),
-				GifPlayback:   proto.Bool(false),
+				Seconds:       proto.Uint32(uint32(videoSeconds)),
+				Height:        proto.Uint32(uint32(videoLength)),
+				Width:         proto.Uint32(uint32(videoLength)),
+				JpegThumbnail: videoThumbnail,
 				ContextInfo:   &waProto.ContextInfo{},
 			},
 		}
 		if isReply {
-			msgToSend.VideoMessage.ContextInfo.StanzaId = proto.String(stanzaId)
-			msgToSend.VideoMessage.ContextInfo.Participant = proto.String(participant)
-			msgToSend.VideoMessage.ContextInfo.QuotedMessage = &waProto.Message{Conversation: proto.String("")}
+			msgToSend.PtvMessage.ContextInfo.StanzaId = proto.String(stanzaId)
+			msgToSend.PtvMessage.ContextInfo.Participant = proto.String(participant)
+			msgToSend.PtvMessage.ContextInfo.QuotedMessage = &waProto.Message{Conversation: proto.String("")}
 		}
 		if len(mentions) > 0 {
-			msgToSend.VideoMessage.ContextInfo.MentionedJid = mentions
+			msgToSend.PtvMessage.ContextInfo.MentionedJid = mentions
 		}
 
-		sentMsg, err := waClient.SendMessage(context.Background(), waChatJID, msgToSend)
+		sentMsg, err := WaSendMessage(account, waChatJID, msgToSend)
 		if err != nil {
 			return TgReplyWithErrorByContext(b, c, "Failed to send video note to WhatsApp", err)
 		}
@@ -409,8 +489,8 @@ func TgSendToWhatsApp(b *gotgbot.Bot, c *ext.Context,
 			}(b, msg)
 		}
 
-		err = database.MsgIdAddNewPair(sentMsg.ID, waClient.Store.ID.String(), waChatJID.String(),
-			cfg.Telegram.TargetChatID, msgToForward.MessageId, msgToForward.MessageThreadId)
+		err = database.MsgIdAddNewPair(account.Name, sentMsg.ID, waClient.Store.ID.String(), waChatJID.String(),
+			msgToForward.Chat.Id, msgToForward.MessageId, msgToForward.MessageThreadId)
 		if err != nil {
 			return TgReplyWithErrorByContext(b, c, "Failed to add to database", err)
 		}
@@ -440,21 +520,25 @@ func TgSendToWhatsApp(b *gotgbot.Bot, c *ext.Context,
 			return TgReplyWithErrorByContext(b, c, "Failed to upload animation to WhatsApp", err)
 		}
 
+		animationWidth, animationHeight, animationSeconds, animationThumbnail := TgGetVideoDetails(b, animationBytes,
+			msgToForward.Animation.Width, msgToForward.Animation.Height, msgToForward.Animation.Duration, msgToForward.Animation.Thumbnail)
+
 		msgToSend := &waProto.Message{
 			VideoMessage: &waProto.VideoMessage{
 				Caption:        proto.String(msgToForward.Caption),
 				Url:            proto.String(uploadedAnimation.URL),
 				DirectPath:     proto.String(uploadedAnimation.DirectPath),
 				MediaKey:       uploadedAnimation.MediaKey,
-				Mimetype:       proto.String(msgToForward.Animation.MimeType),
+				Mimetype:       proto.String(DetectMimeType(animationBytes, msgToForward.Animation.MimeType)),
 				GifPlayback:    proto.Bool(true),
 				FileEncSha256:  uploadedAnimation.FileEncSHA256,
 				FileSha256:     uploadedAnimation.FileSHA256,
 				FileLength:     proto.Uint64(uint64(len(animationBytes))),
 				ViewOnce:       proto.Bool(msgToForward.HasProtectedContent),
-				Height:         proto.Uint32(uint32(msgToForward.Animation.Height)),
-				Width:          proto.Uint32(uint32(msgToForward.Animation.Width)),
-				Seconds:        proto.Uint32(uint32(msgToForward.Animation.Duration)),
+				Height:         proto.Uint32(animationHeight),
+				Width:          proto.Uint32(animationWidth),
+				Seconds:        proto.Uint32(animationSeconds),
+				JpegThumbnail:  animationThumbnail,
 				GifAttribution: waProto.VideoMessage_TENOR.Enum(),
 				ContextInfo:    &waProto.ContextInfo{},
 			},
@@ -468,7 +552,7 @@ func TgSendToWhatsApp(b *gotgbot.Bot, c *ext.Context,
 			msgToSend.VideoMessage.ContextInfo.MentionedJid = mentions
 		}
 
-		sentMsg, err := waClient.SendMessage(context.Background(), waChatJID, msgToSend)
+		sentMsg, err := WaSendMessage(account, waChatJID, msgToSend)
 		if err != nil {
 			return TgReplyWithErrorByContext(b, c, "Failed to send animation to WhatsApp", err)
 		}
@@ -481,8 +565,8 @@ func TgSendToWhatsApp(b *gotgbot.Bot, c *ext.Context,
 			}(b, msg)
 		}
 
-		err = database.MsgIdAddNewPair(sentMsg.ID, waClient.Store.ID.String(), waChatJID.String(),
-			cfg.Telegram.TargetChatID, msgToForward.MessageId, msgToForward.MessageThreadId)
+		err = database.MsgIdAddNewPair(account.Name, sentMsg.ID, waClient.Store.ID.String(), waChatJID.String(),
+			msgToForward.Chat.Id, msgToForward.MessageId, msgToForward.MessageThreadId)
 		if err != nil {
 			return TgReplyWithErrorByContext(b, c, "Failed to add to database", err)
 		}
@@ -506,6 +590,7 @@ func TgSendToWhatsApp(b *gotgbot.Bot, c *ext.Context,
 		if err != nil {
 			return TgReplyWithErrorByContext(b, c, "Failed to download audio from Telegram", err)
 		}
+		audioBytes, audioMimeType := ConvertAudioForWhatsApp(audioBytes, msgToForward.Audio.MimeType)
 
 		uploadedAudio, err := waClient.Upload(context.Background(), audioBytes, whatsmeow.MediaAudio)
 		if err != nil {
@@ -517,7 +602,7 @@ func TgSendToWhatsApp(b *gotgbot.Bot, c *ext.Context,
 				Url:           proto.String(uploadedAudio.URL),
 				DirectPath:    proto.String(uploadedAudio.DirectPath),
 				MediaKey:      uploadedAudio.MediaKey,
-				Mimetype:      proto.String(msgToForward.Audio.MimeType),
+				Mimetype:      proto.String(audioMimeType),
 				FileEncSha256: uploadedAudio.FileEncSHA256,
 				FileSha256:    uploadedAudio.FileSHA256,
 				FileLength:    proto.Uint64(uint64(len(audioBytes))),
@@ -535,7 +620,7 @@ func TgSendToWhatsApp(b *gotgbot.Bot, c *ext.Context,
 			msgToSend.AudioMessage.ContextInfo.MentionedJid = mentions
 		}
 
-		sentMsg, err := waClient.SendMessage(context.Background(), waChatJID, msgToSend)
+		sentMsg, err := WaSendMessage(account, waChatJID, msgToSend)
 		if err != nil {
 			return TgReplyWithErrorByContext(b, c, "Failed to send audio to WhatsApp", err)
 		}
@@ -548,8 +633,8 @@ func TgSendToWhatsApp(b *gotgbot.Bot, c *ext.Context,
 			}(b, msg)
 		}
 
-		err = database.MsgIdAddNewPair(sentMsg.ID, waClient.Store.ID.String(), waChatJID.String(),
-			cfg.Telegram.TargetChatID, msgToForward.MessageId, msgToForward.MessageThreadId)
+		err = database.MsgIdAddNewPair(account.Name, sentMsg.ID, waClient.Store.ID.String(), waChatJID.String(),
+			msgToForward.Chat.Id, msgToForward.MessageId, msgToForward.MessageThreadId)
 		if err != nil {
 			return TgReplyWithErrorByContext(b, c, "Failed to add to database", err)
 		}
@@ -573,6 +658,7 @@ func TgSendToWhatsApp(b *gotgbot.Bot, c *ext.Context,
 		if err != nil {
 			return TgReplyWithErrorByContext(b, c, "Failed to download voice from Telegram", err)
 		}
+		voiceBytes, voiceSeconds, voiceWaveform := ConvertVoiceForWhatsApp(voiceBytes, uint32(msgToForward.Voice.Duration))
 
 		uploadedVoice, err := waClient.Upload(context.Background(), voiceBytes, whatsmeow.MediaAudio)
 		if err != nil {
@@ -588,8 +674,9 @@ func TgSendToWhatsApp(b *gotgbot.Bot, c *ext.Context,
 				FileEncSha256: uploadedVoice.FileEncSHA256,
 				FileSha256:    uploadedVoice.FileSHA256,
 				FileLength:    proto.Uint64(uint64(len(voiceBytes))),
-				Seconds:       proto.Uint32(uint32(msgToForward.Voice.Duration)),
+				Seconds:       proto.Uint32(voiceSeconds),
 				Ptt:           proto.Bool(true),
+				Waveform:      voiceWaveform,
 				ContextInfo:   &waProto.ContextInfo{},
 			},
 		}
@@ -602,7 +689,7 @@ func TgSendToWhatsApp(b *gotgbot.Bot, c *ext.Context,
 			msgToSend.AudioMessage.ContextInfo.MentionedJid = mentions
 		}
 
-		sentMsg, err := waClient.SendMessage(context.Background(), waChatJID, msgToSend)
+		sentMsg, err := WaSendMessage(account, waChatJID, msgToSend)
 		if err != nil {
 			return TgReplyWithErrorByContext(b, c, "Failed to send voice to WhatsApp", err)
 		}
@@ -615,8 +702,8 @@ func TgSendToWhatsApp(b *gotgbot.Bot, c *ext.Context,
 			}(b, msg)
 		}
 
-		err = database.MsgIdAddNewPair(sentMsg.ID, waClient.Store.ID.String(), waChatJID.String(),
-			cfg.Telegram.TargetChatID, msgToForward.MessageId, msgToForward.MessageThreadId)
+		err = database.MsgIdAddNewPair(account.Name, sentMsg.ID, waClient.Store.ID.String(), waChatJID.String(),
+			msgToForward.Chat.Id, msgToForward.MessageId, msgToForward.MessageThreadId)
 		if err != nil {
 			return TgReplyWithErrorByContext(b, c, "Failed to add to database", err)
 		}
@@ -646,17 +733,26 @@ func TgSendToWhatsApp(b *gotgbot.Bot, c *ext.Context,
 			return TgReplyWithErrorByContext(b, c, "Failed to upload document to WhatsApp", err)
 		}
 
-		splitName := strings.Split(msgToForward.Document.FileName, ".")
-		documentFileName := strings.Join(splitName[:len(splitName)-1], ".")
+		var (
+			documentMimeType = DetectMimeType(documentBytes, msgToForward.Document.MimeType)
+			documentFileName = MakeFileName(msgToForward.Document.FileName, "document", documentMimeType)
+		)
+
+		documentThumbnail := TgGetThumbnail(b, msgToForward.Document.Thumbnail)
+		if documentThumbnail == nil {
+			documentThumbnail = MakeDocumentThumbnail(documentBytes, documentMimeType)
+		}
 
 		msgToSend := &waProto.Message{
 			DocumentMessage: &waProto.DocumentMessage{
 				Caption:       proto.String(msgToForward.Caption),
-				Title:         proto.String(documentFileName),
+				Title:         proto.String(FileNameTitle(documentFileName)),
+				FileName:      proto.String(documentFileName),
 				Url:           proto.String(uploadedDocument.URL),
 				DirectPath:    proto.String(uploadedDocument.DirectPath),
 				MediaKey:      uploadedDocument.MediaKey,
-				Mimetype:      proto.String(msgToForward.Document.MimeType),
+				Mimetype:      proto.String(documentMimeType),
+				JpegThumbnail: documentThumbnail,
 				FileEncSha256: uploadedDocument.FileEncSHA256,
 				FileSha256:    uploadedDocument.FileSHA256,
 				FileLength:    proto.Uint64(uint64(len(documentBytes))),
@@ -672,7 +768,7 @@ func TgSendToWhatsApp(b *gotgbot.Bot, c *ext.Context,
 			msgToSend.DocumentMessage.ContextInfo.MentionedJid = mentions
 		}
 
-		sentMsg, err := waClient.SendMessage(context.Background(), waChatJID, msgToSend)
+		sentMsg, err := WaSendMessage(account, waChatJID, msgToSend)
 		if err != nil {
 			return TgReplyWithErrorByContext(b, c, "Failed to send document to WhatsApp", err)
 		}
@@ -685,93 +781,21 @@ func TgSendToWhatsApp(b *gotgbot.Bot, c *ext.Context,
 			}(b, msg)
 		}
 
-		err = database.MsgIdAddNewPair(sentMsg.ID, waClient.Store.ID.String(), waChatJID.String(),
-			cfg.Telegram.TargetChatID, msgToForward.MessageId, msgToForward.MessageThreadId)
+		err = database.MsgIdAddNewPair(account.Name, sentMsg.ID, waClient.Store.ID.String(), waChatJID.String(),
+			msgToForward.Chat.Id, msgToForward.MessageId, msgToForward.MessageThreadId)
 		if err != nil {
 			return TgReplyWithErrorByContext(b, c, "Failed to add to database", err)
 		}
-	} else if msgToForward.Sticker != nil {
+	} else if sticker != nil {
 
-		if !cfg.Telegram.SelfHostedAPI && msgToForward.Sticker.FileSize > DownloadSizeLimit {
+		if !cfg.Telegram.SelfHostedAPI && sticker.FileSize > DownloadSizeLimit {
 			_, err := TgReplyTextByContext(b, c, "Unable to send sticker as it exceeds Telegram size restriction", nil)
 			return err
 		}
 
-		stickerFile, err := b.GetFile(msgToForward.Sticker.FileId, &gotgbot.GetFileOpts{
-			RequestOpts: &gotgbot.RequestOpts{
-				Timeout: -1,
-			},
-		})
+		msgToSend, err := TgMakeWhatsAppSticker(b, account, sticker, c.UpdateId)
 		if err != nil {
-			return TgReplyWithErrorByContext(b, c, "Failed to retreive sticker file from Telegram", err)
-		}
-
-		stickerBytes, err := TgDownloadByFilePath(b, stickerFile.FilePath)
-		if err != nil {
-			return TgReplyWithErrorByContext(b, c, "Failed to download sticker from Telegram", err)
-		}
-
-		if msgToForward.Sticker.IsAnimated {
-			stickerBytes, err = TGSConvertToWebp(stickerBytes, c.UpdateId)
-			if err != nil {
-				return TgReplyWithErrorByContext(b, c, "Failed to convert TGS sticker to WebP", err)
-			}
-		} else if msgToForward.Sticker.IsVideo && !cfg.Telegram.SkipVideoStickers {
-
-			var scale, pad string
-
-			if msgToForward.Sticker.Height == 512 && msgToForward.Sticker.Width == 512 {
-				scale = "512:512"
-				pad = "0:0:0:0"
-			} else if msgToForward.Sticker.Height == 512 {
-				scale = "-1:512"
-				pad = fmt.Sprintf("512:512:%v:0", (512-msgToForward.Sticker.Width)/2)
-			} else {
-				scale = "512:-1"
-				pad = fmt.Sprintf("512:512:0:%v", (512-msgToForward.Sticker.Height)/2)
-			}
-
-			stickerBytes, err = WebmConvertToWebp(stickerBytes, scale, pad, c.UpdateId)
-			if err != nil {
-				return TgReplyWithErrorByContext(b, c, "Failed to convert WEBM sticker to GIF", err)
-			}
-		} else if !msgToForward.Sticker.IsAnimated || !msgToForward.Sticker.IsVideo {
-
-			var wPad, hPad int
-
-			if msgToForward.Sticker.Height != 512 {
-				hPad = int(512 - msgToForward.Sticker.Height)
-			}
-			if msgToForward.Sticker.Width != 512 {
-				wPad = int(512 - msgToForward.Sticker.Width)
-			}
-
-			stickerBytes, err = WebpImagePad(stickerBytes, wPad, hPad, c.UpdateId)
-			if err != nil {
-				return TgReplyWithErrorByContext(b, c, "Failed to pad WEBP sticker to 512x512", err)
-			}
-		}
-
-		uploadedSticker, err := waClient.Upload(context.Background(), stickerBytes, whatsmeow.MediaImage)
-		if err != nil {
-			return TgReplyWithErrorByContext(b, c, "Failed to upload sticker to WhatsApp", err)
-		}
-
-		msgToSend := &waProto.Message{
-			StickerMessage: &waProto.StickerMessage{
-				Url:           proto.String(uploadedSticker.URL),
-				DirectPath:    proto.String(uploadedSticker.DirectPath),
-				MediaKey:      uploadedSticker.MediaKey,
-				IsAnimated:    proto.Bool(msgToForward.Sticker.IsAnimated || msgToForward.Sticker.IsVideo),
-				IsAvatar:      proto.Bool(false),
-				Height:        proto.Uint32(uint32(msgToForward.Sticker.Height)),
-				Width:         proto.Uint32(uint32(msgToForward.Sticker.Width)),
-				Mimetype:      proto.String("image/webp"),
-				FileEncSha256: uploadedSticker.FileEncSHA256,
-				FileSha256:    uploadedSticker.FileSHA256,
-				FileLength:    proto.Uint64(uint64(len(stickerBytes))),
-				StickerSentTs: proto.Int64(time.Now().Unix()),
-			},
+			return TgReplyWithErrorByContext(b, c, "Failed to send sticker to WhatsApp", err)
 		}
 		if isReply {
 			msgToSend.StickerMessage.ContextInfo = &waProto.ContextInfo{
@@ -781,7 +805,7 @@ func TgSendToWhatsApp(b *gotgbot.Bot, c *ext.Context,
 			}
 		}
 
-		sentMsg, err := waClient.SendMessage(context.Background(), waChatJID, msgToSend)
+		sentMsg, err := WaSendMessage(account, waChatJID, msgToSend)
 		if err != nil {
 			return TgReplyWithErrorByContext(b, c, "Failed to send sticker to WhatsApp", err)
 		}
@@ -794,8 +818,8 @@ func TgSendToWhatsApp(b *gotgbot.Bot, c *ext.Context,
 			}(b, msg)
 		}
 
-		err = database.MsgIdAddNewPair(sentMsg.ID, waClient.Store.ID.String(), waChatJID.String(),
-			cfg.Telegram.TargetChatID, msgToForward.MessageId, msgToForward.MessageThreadId)
+		err = database.MsgIdAddNewPair(account.Name, sentMsg.ID, waClient.Store.ID.String(), waChatJID.String(),
+			msgToForward.Chat.Id, msgToForward.MessageId, msgToForward.MessageThreadId)
 		if err != nil {
 			return TgReplyWithErrorByContext(b, c, "Failed to add to database", err)
 		}
@@ -826,6 +850,8 @@ func TgSendToWhatsApp(b *gotgbot.Bot, c *ext.Context,
 			return err
 		}
 
+		linkPreview := TgGetLinkPreview(entities)
+
 		msgToSend := &waProto.Message{}
 		if isReply || len(mentions) > 0 {
 			msgToSend.ExtendedTextMessage = &waProto.ExtendedTextMessage{
@@ -839,11 +865,18 @@ func TgSendToWhatsApp(b *gotgbot.Bot, c *ext.Context,
 			if len(mentions) > 0 {
 				msgToSend.ExtendedTextMessage.ContextInfo.MentionedJid = mentions
 			}
+		} else if linkPreview != nil {
+			msgToSend.ExtendedTextMessage = &waProto.ExtendedTextMessage{
+				Text: proto.String(msgToForward.Text),
+			}
 		} else {
 			msgToSend.Conversation = proto.String(msgToForward.Text)
 		}
+		if linkPreview != nil {
+			WaSetLinkPreview(msgToSend.ExtendedTextMessage, linkPreview)
+		}
 
-		sentMsg, err := waClient.SendMessage(context.Background(), waChatJID, msgToSend)
+		sentMsg, err := WaSendMessage(account, waChatJID, msgToSend)
 		if err != nil {
 			return TgReplyWithErrorByContext(b, c, "Failed to send message to WhatsApp", err)
 		}
@@ -856,8 +889,8 @@ func TgSendToWhatsApp(b *gotgbot.Bot, c *ext.Context,
 			}(b, msg)
 		}
 
-		err = database.MsgIdAddNewPair(sentMsg.ID, waClient.Store.ID.String(), waChatJID.String(),
-			cfg.Telegram.TargetChatID, msgToForward.MessageId, msgToForward.MessageThreadId)
+		err = database.MsgIdAddNewPair(account.Name, sentMsg.ID, waClient.Store.ID.String(), waChatJID.String(),
+			msgToForward.Chat.Id, msgToForward.MessageId, msgToForward.MessageThreadId)
 		if err != nil {
 			return TgReplyWithErrorByContext(b, c, "Failed to add to database", err)
 		}
@@ -865,7 +898,7 @@ func TgSendToWhatsApp(b *gotgbot.Bot, c *ext.Context,
 		{
 			textSplit := strings.Fields(strings.ToLower(msgToForward.Text))
 			if slices.Contains(textSplit, "@all") || slices.Contains(textSplit, "@everyone") {
-				WaTagAll(waChatJID, msgToSend, sentMsg.ID, waClient.Store.ID.String(), true)
+				WaTagAll(account, waChatJID, msgToSend, sentMsg.ID, waClient.Store.ID.String(), true)
 			}
 		}
 
@@ -874,6 +907,185 @@ func TgSendToWhatsApp(b *gotgbot.Bot, c *ext.Context,
 	return nil
 }
 
+// TgGetLoneCustomEmoji returns the sticker of the custom emoji which makes up the whole text
+// of the message, or nil if the text is anything else
+func TgGetLoneCustomEmoji(b *gotgbot.Bot, msg *gotgbot.Message) *gotgbot.Sticker {
+	if len(msg.Entities) != 1 {
+		return nil
+	}
+	entity := msg.ParseEntity(msg.Entities[0])
+	if entity.Type != "custom_emoji" || entity.Text != msg.Text {
+		return nil
+	}
+
+	stickers, err := b.GetCustomEmojiStickers([]string{entity.CustomEmojiId}, &gotgbot.GetCustomEmojiStickersOpts{})
+	if err != nil || len(stickers) == 0 {
+		return nil
+	}
+	return &stickers[0]
+}
+
+// TgReplaceCustomEmojis replaces the custom emojis in the text with the emojis they are
+// based on, which are not always the ones Telegram puts in the text for them
+func TgReplaceCustomEmojis(b *gotgbot.Bot, text string, entities []gotgbot.ParsedMessageEntity) string {
+	var customEmojiIds []string
+	for _, entity := range entities {
+		if entity.Type == "custom_emoji" {
+			customEmojiIds = append(customEmojiIds, entity.CustomEmojiId)
+		}
+	}
+	if len(customEmojiIds) == 0 {
+		return text
+	}
+
+	stickers, err := b.GetCustomEmojiStickers(customEmojiIds, &gotgbot.GetCustomEmojiStickersOpts{})
+	if err != nil {
+		return text
+	}
+	emojis := make(map[string]string)
+	for _, sticker := range stickers {
+		emojis[sticker.CustomEmojiId] = sticker.Emoji
+	}
+
+	var (
+		newText strings.Builder
+		lastEnd int64
+	)
+	for _, entity := range entities {
+		emoji, found := emojis[entity.CustomEmojiId]
+		if entity.Type != "custom_emoji" || !found || emoji == "" || entity.Offset < lastEnd {
+			continue
+		}
+		newText.WriteString(text[lastEnd:entity.Offset])
+		newText.WriteString(emoji)
+		lastEnd = entity.Offset + entity.Length
+	}
+	newText.WriteString(text[lastEnd:])
+	return newText.String()
+}
+
+// TgMakeWhatsAppSticker converts the sticker into a 512x512 webp and uploads it to WhatsApp,
+// reusing the converted sticker and its upload from the cache when they are there
+func TgMakeWhatsAppSticker(b *gotgbot.Bot, account *state.WhatsAppAccount, sticker *gotgbot.Sticker, updateId int64) (*waProto.Message, error) {
+	var (
+		cfg    = state.State.Config
+		logger = state.State.Logger
+	)
+	defer logger.Sync()
+
+	cacheKey := StickerCacheKeyTg(sticker.FileUniqueId, "webp")
+	stickerBytes, cacheEntry := StickerCacheGet(cacheKey)
+	if stickerBytes == nil {
+		stickerFile, err := b.GetFile(sticker.FileId, &gotgbot.GetFileOpts{
+			RequestOpts: &gotgbot.RequestOpts{
+				Timeout: -1,
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to retreive sticker file from Telegram: %s", err)
+		}
+
+		stickerBytes, err = TgDownloadByFilePath(b, stickerFile.FilePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download sticker from Telegram: %s", err)
+		}
+
+		if sticker.IsAnimated {
+			stickerBytes, err = TGSConvertToWebp(stickerBytes, updateId)
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert TGS sticker to WebP: %s", err)
+			}
+		} else if (sticker.IsVideo && !cfg.Telegram.SkipVideoStickers) || (sticker.Height < 512 && sticker.Width < 512) {
+			// Static custom emojis are only 100px, and are scaled up along with the video stickers
+			stickerBytes, err = WebmConvertToWebp(stickerBytes)
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert sticker to 512x512 WebP: %s", err)
+			}
+		} else {
+
+			var wPad, hPad int
+
+			if sticker.Height != 512 {
+				hPad = int(512 - sticker.Height)
+			}
+			if sticker.Width != 512 {
+				wPad = int(512 - sticker.Width)
+			}
+
+			stickerBytes, err = WebpImagePad(stickerBytes, wPad, hPad)
+			if err != nil {
+				return nil, fmt.Errorf("failed to pad WEBP sticker to 512x512: %s", err)
+			}
+		}
+
+		if err := StickerCachePut(cacheKey, stickerBytes); err != nil {
+			logger.Warn("failed to cache converted sticker",
+				zap.String("key", cacheKey),
+				zap.Error(err),
+			)
+		}
+	}
+
+	uploadedSticker, found := StickerCacheGetWaUpload(cacheEntry)
+	if !found {
+		var err error
+		uploadedSticker, err = account.Client.Upload(context.Background(), stickerBytes, whatsmeow.MediaImage)
+		if err != nil {
+			return nil, fmt.Errorf("failed to upload sticker to WhatsApp: %s", err)
+		}
+		StickerCacheSetWaUpload(cacheKey, uploadedSticker)
+	}
+
+	return &waProto.Message{
+		StickerMessage: &waProto.StickerMessage{
+			Url:           proto.String(uploadedSticker.URL),
+			DirectPath:    proto.String(uploadedSticker.DirectPath),
+			MediaKey:      uploadedSticker.MediaKey,
+			IsAnimated:    proto.Bool(sticker.IsAnimated || sticker.IsVideo),
+			IsAvatar:      proto.Bool(false),
+			Height:        proto.Uint32(uint32(sticker.Height)),
+			Width:         proto.Uint32(uint32(sticker.Width)),
+			Mimetype:      proto.String("image/webp"),
+			FileEncSha256: uploadedSticker.FileEncSHA256,
+			FileSha256:    uploadedSticker.FileSHA256,
+			FileLength:    proto.Uint64(uint64(len(stickerBytes))),
+			StickerSentTs: proto.Int64(time.Now().Unix()),
+		},
+	}, nil
+}
+
+// TgAddStickerToBotSet adds the sticker to the set of its format which the bot keeps for the
+// owner, creating the set if it does not exist yet, and returns the name of the set
+func TgAddStickerToBotSet(b *gotgbot.Bot, sticker *gotgbot.Sticker) (string, error) {
+	ownerId := state.State.Config.Telegram.OwnerID
+
+	stickerFormat, title := "static", "WhatsApp stickers"
+	if sticker.IsVideo {
+		stickerFormat, title = "video", "WhatsApp video stickers"
+	} else if sticker.IsAnimated {
+		stickerFormat, title = "animated", "WhatsApp animated stickers"
+	}
+	setName := fmt.Sprintf("wa_%s_%v_by_%s", stickerFormat, ownerId, b.Username)
+
+	emoji := sticker.Emoji
+	if emoji == "" {
+		emoji = "😀"
+	}
+	inputSticker := gotgbot.InputSticker{
+		Sticker:   sticker.FileId,
+		EmojiList: []string{emoji},
+	}
+
+	if _, err := b.GetStickerSet(setName, &gotgbot.GetStickerSetOpts{}); err != nil {
+		_, err = b.CreateNewStickerSet(ownerId, setName, title, []gotgbot.InputSticker{inputSticker}, stickerFormat,
+			&gotgbot.CreateNewStickerSetOpts{})
+		return setName, err
+	}
+
+	_, err := b.AddStickerToSet(ownerId, setName, inputSticker, &gotgbot.AddStickerToSetOpts{})
+	return setName, err
+}
+
 func TgMakeRevokeKeyboard(msgId, chatId string, confirm bool) *gotgbot.InlineKeyboardMarkup {
 
 	if confirm {
@@ -0,0 +1,158 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"image/jpeg"
+	"math"
+	"strings"
+
+	"watgbridge/state"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"go.uber.org/zap"
+)
+
+// Longest side of the thumbnails which WhatsApp shows before media is downloaded
+const thumbnailMaxLength = 100
+
+// MakeJpegThumbnail scales the image down into a small JPEG thumbnail, returning it
+// along with the width and height of the image
+func MakeJpegThumbnail(imageData []byte) ([]byte, int, int, error) {
+	img, err := decodeStaticImage(imageData)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	imageWidth, imageHeight := img.Bounds().Dx(), img.Bounds().Dy()
+	if imageWidth < 1 || imageHeight < 1 {
+		return nil, 0, 0, errors.New("image is empty")
+	}
+
+	var (
+		scale  = math.Min(1, thumbnailMaxLength/math.Max(float64(imageWidth), float64(imageHeight)))
+		width  = clampInt(int(math.Round(float64(imageWidth)*scale)), 1, thumbnailMaxLength)
+		height = clampInt(int(math.Round(float64(imageHeight)*scale)), 1, thumbnailMaxLength)
+	)
+
+	var thumbnail bytes.Buffer
+	if err := jpeg.Encode(&thumbnail, scaleImage(img, width, height), &jpeg.Options{Quality: 70}); err != nil {
+		return nil, 0, 0, err
+	}
+	return thumbnail.Bytes(), imageWidth, imageHeight, nil
+}
+
+// TgGetThumbnail downloads the thumbnail Telegram made for the media and returns it as
+// a JPEG thumbnail, or nothing if it has none
+func TgGetThumbnail(b *gotgbot.Bot, thumbnail *gotgbot.PhotoSize) []byte {
+	logger := state.State.Logger
+	defer logger.Sync()
+
+	if thumbnail == nil {
+		return nil
+	}
+
+	thumbnailFile, err := b.GetFile(thumbnail.FileId, &gotgbot.GetFileOpts{})
+	if err != nil {
+		logger.Debug("failed to retrieve thumbnail file from Telegram",
+			zap.Error(err),
+		)
+		return nil
+	}
+
+	thumbnailBytes, err := TgDownloadByFilePath(b, thumbnailFile.FilePath)
+	if err != nil {
+		logger.Debug("failed to download thumbnail from Telegram",
+			zap.Error(err),
+		)
+		return nil
+	}
+
+	jpegThumbnail, _, _, err := MakeJpegThumbnail(thumbnailBytes)
+	if err != nil {
+		logger.Debug("failed to make thumbnail out of Telegram's",
+			zap.Error(err),
+		)
+		return nil
+	}
+	return jpegThumbnail
+}
+
+// ProbeVideo returns the dimensions, duration and thumbnail of the video, those which
+// cannot be found being left empty
+func ProbeVideo(videoData []byte) (int, int, uint32, []byte) {
+	logger := state.State.Logger
+	defer logger.Sync()
+
+	info, err := Converter.ProbeVideo(videoData)
+	if err != nil {
+		if !errors.Is(err, ErrConversionUnsupported) {
+			logger.Debug("failed to probe video",
+				zap.Error(err),
+			)
+		}
+		return 0, 0, 0, nil
+	}
+
+	var thumbnail []byte
+	if len(info.FirstFrame) > 0 {
+		thumbnail, _, _, _ = MakeJpegThumbnail(info.FirstFrame)
+	}
+	return info.Width, info.Height, info.Seconds, thumbnail
+}
+
+// MakeDocumentThumbnail makes a thumbnail out of the preview of a document, which is
+// the image itself, the first frame of videos or the first page of PDFs. Nothing is
+// returned for the other kinds of documents.
+func MakeDocumentThumbnail(documentData []byte, mimeType string) []byte {
+	logger := state.State.Logger
+	defer logger.Sync()
+
+	mediaType := BaseMimeType(mimeType)
+	switch {
+	case strings.HasPrefix(mediaType, "image/"):
+		thumbnail, _, _, _ := MakeJpegThumbnail(documentData)
+		return thumbnail
+
+	case strings.HasPrefix(mediaType, "video/"):
+		_, _, _, thumbnail := ProbeVideo(documentData)
+		return thumbnail
+
+	case mediaType == "application/pdf":
+		pageData, err := Converter.PdfFirstPage(documentData)
+		if err != nil {
+			if !errors.Is(err, ErrConversionUnsupported) {
+				logger.Debug("failed to render first page of PDF",
+					zap.Error(err),
+				)
+			}
+			return nil
+		}
+		thumbnail, _, _, _ := MakeJpegThumbnail(pageData)
+		return thumbnail
+	}
+
+	return nil
+}
+
+// TgGetVideoDetails returns the width, height, duration and thumbnail of a video sent
+// from Telegram, keeping what Telegram has sent along with it and probing the video
+// for the rest
+func TgGetVideoDetails(b *gotgbot.Bot, videoData []byte, width, height, seconds int64, thumbnail *gotgbot.PhotoSize) (uint32, uint32, uint32, []byte) {
+	jpegThumbnail := TgGetThumbnail(b, thumbnail)
+	if jpegThumbnail != nil && width > 0 && height > 0 && seconds > 0 {
+		return uint32(width), uint32(height), uint32(seconds), jpegThumbnail
+	}
+
+	probedWidth, probedHeight, probedSeconds, probedThumbnail := ProbeVideo(videoData)
+	if width <= 0 || height <= 0 {
+		width, height = int64(probedWidth), int64(probedHeight)
+	}
+	if seconds <= 0 {
+		seconds = int64(probedSeconds)
+	}
+	if jpegThumbnail == nil {
+		jpegThumbnail = probedThumbnail
+	}
+	return uint32(width), uint32(height), uint32(seconds), jpegThumbnail
+}
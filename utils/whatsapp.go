@@ -14,6 +14,7 @@ import (
 	"go.mau.fi/whatsmeow"
 	waProto "go.mau.fi/whatsmeow/binary/proto"
 	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
 	"google.golang.org/protobuf/proto"
 )
 
@@ -96,8 +97,8 @@ func WaFuzzyFindContacts(query string) (map[string]string, int, error) {
 	return results, resultsCount, nil
 }
 
-func WaGetGroupName(jid types.JID) string {
-	waClient := state.State.WhatsAppClient
+func WaGetGroupName(account *state.WhatsAppAccount, jid types.JID) string {
+	waClient := account.Client
 
 	groupInfo, err := waClient.GetGroupInfo(jid)
 	if err != nil {
@@ -143,10 +144,38 @@ func WaGetContactName(jid types.JID) string {
 	return name
 }
 
-func WaTagAll(group types.JID, msg *waProto.Message, msgId, msgSender string, msgIsFromMe bool) {
+// WaGetTargetChat returns the Telegram chat which messages from the WhatsApp chat are
+// bridged into, as decided by the routes of the account
+func WaGetTargetChat(account *state.WhatsAppAccount, chat, sender types.JID) int64 {
+	if chat == types.StatusBroadcastJID {
+		return account.RouteChat(sender, true, func() string { return WaGetContactName(sender) })
+	} else if chat.Server == types.GroupServer {
+		return account.RouteChat(chat, false, func() string { return WaGetGroupName(account, chat) })
+	}
+	return account.RouteChat(chat, false, func() string { return WaGetContactName(chat) })
+}
+
+// WaIsMentioned tells if the account is mentioned in the message
+func WaIsMentioned(account *state.WhatsAppAccount, msg *waProto.Message) bool {
+	for _, contextInfo := range []*waProto.ContextInfo{
+		msg.GetExtendedTextMessage().GetContextInfo(),
+		msg.GetImageMessage().GetContextInfo(),
+		msg.GetVideoMessage().GetContextInfo(),
+		msg.GetPtvMessage().GetContextInfo(),
+		msg.GetDocumentMessage().GetContextInfo(),
+	} {
+		for _, jid := range contextInfo.GetMentionedJid() {
+			if parsedJid, _ := WaParseJID(jid); parsedJid.User == account.Client.Store.ID.User {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func WaTagAll(account *state.WhatsAppAccount, group types.JID, msg *waProto.Message, msgId, msgSender string, msgIsFromMe bool) {
 	var (
-		cfg      = state.State.Config
-		waClient = state.State.WhatsAppClient
+		waClient = account.Client
 		tgBot    = state.State.TelegramBot
 	)
 
@@ -187,22 +216,68 @@ func WaTagAll(group types.JID, msg *waProto.Message, msgId, msgSender string, ms
 	}
 
 	if !msgIsFromMe {
-		tagsThreadId, err := TgGetOrMakeThreadFromWa("status@broadcast", cfg.Telegram.TargetChatID, "Status/Calls/Tags [ status@broadcast ]")
+		targetChatId := account.RouteChat(group, false, func() string { return groupInfo.Name })
+
+		tagsThreadId, err := TgGetOrMakeSystemThread(account, SystemTopicTagAlls, targetChatId)
 		if err != nil {
-			TgSendErrorById(tgBot, cfg.Telegram.TargetChatID, 0, "Failed to create/retreive corresponding thread id for status/calls/tags", err)
+			TgSendBridgeError(account, targetChatId, "Failed to create/retreive corresponding thread id for tag alls", err)
 			return
 		}
 
 		bridgedText := fmt.Sprintf("#tagall\n\nEveryone was mentioned in a group\n\n👥: <i>%s</i>",
 			html.EscapeString(groupInfo.Name))
 
-		TgSendTextById(tgBot, cfg.Telegram.TargetChatID, tagsThreadId, bridgedText)
+		TgSendTextById(tgBot, targetChatId, tagsThreadId, bridgedText)
 	}
 }
 
-func WaSendText(chat types.JID, text, stanzaId, participantId string, quotedMsg *waProto.Message, isReply bool) (whatsmeow.SendResponse, error) {
-	waClient := state.State.WhatsAppClient
+// WaNormalizeMessage unwraps all the containers around the content of the message, in
+// whatever order they are nested, recording them in the flags of the event. whatsmeow
+// only unwraps them once each and in a fixed order. The ID of the message which was
+// edited is returned for edits, when it is known.
+func WaNormalizeMessage(v *events.Message) (editedMsgId string) {
+	for v.Message != nil {
+		msg := v.Message
+		switch {
+		case msg.GetDeviceSentMessage().GetMessage() != nil:
+			if v.Info.DeviceSentMeta == nil {
+				v.Info.DeviceSentMeta = &types.DeviceSentMeta{
+					DestinationJID: msg.GetDeviceSentMessage().GetDestinationJid(),
+					Phash:          msg.GetDeviceSentMessage().GetPhash(),
+				}
+			}
+			v.Message = msg.GetDeviceSentMessage().GetMessage()
+		case msg.GetEphemeralMessage().GetMessage() != nil:
+			v.Message = msg.GetEphemeralMessage().GetMessage()
+			v.IsEphemeral = true
+		case msg.GetViewOnceMessage().GetMessage() != nil:
+			v.Message = msg.GetViewOnceMessage().GetMessage()
+			v.IsViewOnce = true
+		case msg.GetViewOnceMessageV2().GetMessage() != nil:
+			v.Message = msg.GetViewOnceMessageV2().GetMessage()
+			v.IsViewOnce, v.IsViewOnceV2 = true, true
+		case msg.GetViewOnceMessageV2Extension().GetMessage() != nil:
+			v.Message = msg.GetViewOnceMessageV2Extension().GetMessage()
+			v.IsViewOnce, v.IsViewOnceV2 = true, true
+		case msg.GetDocumentWithCaptionMessage().GetMessage() != nil:
+			v.Message = msg.GetDocumentWithCaptionMessage().GetMessage()
+			v.IsDocumentWithCaption = true
+		case msg.GetEditedMessage().GetMessage() != nil:
+			v.Message = msg.GetEditedMessage().GetMessage()
+			v.IsEdit = true
+		case msg.GetProtocolMessage().GetType() == waProto.ProtocolMessage_MESSAGE_EDIT &&
+			msg.GetProtocolMessage().GetEditedMessage() != nil:
+			editedMsgId = msg.GetProtocolMessage().GetKey().GetId()
+			v.Message = msg.GetProtocolMessage().GetEditedMessage()
+			v.IsEdit = true
+		default:
+			return editedMsgId
+		}
+	}
+	return editedMsgId
+}
 
+func WaSendText(account *state.WhatsAppAccount, chat types.JID, text, stanzaId, participantId string, quotedMsg *waProto.Message, isReply bool) (whatsmeow.SendResponse, error) {
 	msgToSend := &waProto.Message{}
 	if isReply {
 		msgToSend.ExtendedTextMessage = &waProto.ExtendedTextMessage{
@@ -217,5 +292,5 @@ func WaSendText(chat types.JID, text, stanzaId, participantId string, quotedMsg
 		msgToSend.Conversation = proto.String(text)
 	}
 
-	return waClient.SendMessage(context.Background(), chat, msgToSend)
+	return WaSendMessage(account, chat, msgToSend)
 }
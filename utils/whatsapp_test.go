@@ -0,0 +1,174 @@
+package utils
+
+import (
+	"testing"
+
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestWaNormalizeMessage(t *testing.T) {
+	var (
+		text     = &waProto.Message{Conversation: proto.String("hello")}
+		image    = &waProto.Message{ImageMessage: &waProto.ImageMessage{Caption: proto.String("photo")}}
+		document = &waProto.Message{DocumentMessage: &waProto.DocumentMessage{Caption: proto.String("file")}}
+	)
+
+	editOf := func(msgId string, edited *waProto.Message) *waProto.Message {
+		return &waProto.Message{ProtocolMessage: &waProto.ProtocolMessage{
+			Type:          waProto.ProtocolMessage_MESSAGE_EDIT.Enum(),
+			Key:           &waProto.MessageKey{Id: proto.String(msgId)},
+			EditedMessage: edited,
+		}}
+	}
+
+	type flags struct {
+		ephemeral, viewOnce, viewOnceV2, documentWithCaption, edit, deviceSent bool
+	}
+
+	tests := []struct {
+		name            string
+		message         *waProto.Message
+		want            *waProto.Message
+		wantFlags       flags
+		wantEditedMsgId string
+	}{
+		{
+			name:    "plain message",
+			message: text,
+			want:    text,
+		},
+		{
+			name: "device sent",
+			message: &waProto.Message{DeviceSentMessage: &waProto.DeviceSentMessage{
+				DestinationJid: proto.String("1234@s.whatsapp.net"),
+				Message:        text,
+			}},
+			want:      text,
+			wantFlags: flags{deviceSent: true},
+		},
+		{
+			name:      "ephemeral",
+			message:   &waProto.Message{EphemeralMessage: &waProto.FutureProofMessage{Message: text}},
+			want:      text,
+			wantFlags: flags{ephemeral: true},
+		},
+		{
+			name:      "view once",
+			message:   &waProto.Message{ViewOnceMessage: &waProto.FutureProofMessage{Message: image}},
+			want:      image,
+			wantFlags: flags{viewOnce: true},
+		},
+		{
+			name:      "view once v2",
+			message:   &waProto.Message{ViewOnceMessageV2: &waProto.FutureProofMessage{Message: image}},
+			want:      image,
+			wantFlags: flags{viewOnce: true, viewOnceV2: true},
+		},
+		{
+			name:      "view once v2 extension",
+			message:   &waProto.Message{ViewOnceMessageV2Extension: &waProto.FutureProofMessage{Message: image}},
+			want:      image,
+			wantFlags: flags{viewOnce: true, viewOnceV2: true},
+		},
+		{
+			name:      "document with caption",
+			message:   &waProto.Message{DocumentWithCaptionMessage: &waProto.FutureProofMessage{Message: document}},
+			want:      document,
+			wantFlags: flags{documentWithCaption: true},
+		},
+		{
+			name:            "edited message",
+			message:         &waProto.Message{EditedMessage: &waProto.FutureProofMessage{Message: editOf("ORIGINAL", text)}},
+			want:            text,
+			wantFlags:       flags{edit: true},
+			wantEditedMsgId: "ORIGINAL",
+		},
+		{
+			name:            "protocol message edit",
+			message:         editOf("ORIGINAL", text),
+			want:            text,
+			wantFlags:       flags{edit: true},
+			wantEditedMsgId: "ORIGINAL",
+		},
+		{
+			name: "protocol message revoke is kept",
+			message: &waProto.Message{ProtocolMessage: &waProto.ProtocolMessage{
+				Type: waProto.ProtocolMessage_REVOKE.Enum(),
+				Key:  &waProto.MessageKey{Id: proto.String("ORIGINAL")},
+			}},
+			want: &waProto.Message{ProtocolMessage: &waProto.ProtocolMessage{
+				Type: waProto.ProtocolMessage_REVOKE.Enum(),
+				Key:  &waProto.MessageKey{Id: proto.String("ORIGINAL")},
+			}},
+		},
+		{
+			name: "document with caption in ephemeral",
+			message: &waProto.Message{EphemeralMessage: &waProto.FutureProofMessage{Message: &waProto.Message{
+				DocumentWithCaptionMessage: &waProto.FutureProofMessage{Message: document},
+			}}},
+			want:      document,
+			wantFlags: flags{ephemeral: true, documentWithCaption: true},
+		},
+		{
+			name: "ephemeral in document with caption",
+			message: &waProto.Message{DocumentWithCaptionMessage: &waProto.FutureProofMessage{Message: &waProto.Message{
+				EphemeralMessage: &waProto.FutureProofMessage{Message: document},
+			}}},
+			want:      document,
+			wantFlags: flags{ephemeral: true, documentWithCaption: true},
+		},
+		{
+			name: "view once in ephemeral sent from another device",
+			message: &waProto.Message{DeviceSentMessage: &waProto.DeviceSentMessage{
+				Message: &waProto.Message{EphemeralMessage: &waProto.FutureProofMessage{Message: &waProto.Message{
+					ViewOnceMessageV2: &waProto.FutureProofMessage{Message: image},
+				}}},
+			}},
+			want:      image,
+			wantFlags: flags{deviceSent: true, ephemeral: true, viewOnce: true, viewOnceV2: true},
+		},
+		{
+			name: "edit of a document with caption in ephemeral",
+			message: &waProto.Message{EphemeralMessage: &waProto.FutureProofMessage{Message: &waProto.Message{
+				EditedMessage: &waProto.FutureProofMessage{Message: editOf("ORIGINAL", &waProto.Message{
+					DocumentWithCaptionMessage: &waProto.FutureProofMessage{Message: document},
+				})},
+			}}},
+			want:            document,
+			wantFlags:       flags{ephemeral: true, edit: true, documentWithCaption: true},
+			wantEditedMsgId: "ORIGINAL",
+		},
+		{
+			name:    "empty wrapper is kept",
+			message: &waProto.Message{EphemeralMessage: &waProto.FutureProofMessage{}},
+			want:    &waProto.Message{EphemeralMessage: &waProto.FutureProofMessage{}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			v := &events.Message{Message: test.message, RawMessage: test.message}
+			editedMsgId := WaNormalizeMessage(v)
+
+			if !proto.Equal(v.Message, test.want) {
+				t.Errorf("message = %v, want %v", v.Message, test.want)
+			}
+			gotFlags := flags{
+				ephemeral:           v.IsEphemeral,
+				viewOnce:            v.IsViewOnce,
+				viewOnceV2:          v.IsViewOnceV2,
+				documentWithCaption: v.IsDocumentWithCaption,
+				edit:                v.IsEdit,
+				deviceSent:          v.Info.DeviceSentMeta != nil,
+			}
+			if gotFlags != test.wantFlags {
+				t.Errorf("flags = %+v, want %+v", gotFlags, test.wantFlags)
+			}
+			if editedMsgId != test.wantEditedMsgId {
+				t.Errorf("edited message ID = %q, want %q", editedMsgId, test.wantEditedMsgId)
+			}
+		})
+	}
+}
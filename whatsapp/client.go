@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"os"
 
+	"watgbridge/database"
 	"watgbridge/state"
+	"watgbridge/utils"
 
 	"github.com/PaulSonOfLars/gotgbot/v2"
 	_ "github.com/jackc/pgx/v5"
@@ -15,9 +17,11 @@ import (
 	waProto "go.mau.fi/whatsmeow/binary/proto"
 	"go.mau.fi/whatsmeow/store"
 	"go.mau.fi/whatsmeow/store/sqlstore"
+	waTypes "go.mau.fi/whatsmeow/types"
 	waLog "go.mau.fi/whatsmeow/util/log"
 	"go.uber.org/zap"
 	"google.golang.org/protobuf/proto"
+	"rsc.io/qr"
 )
 
 type whatsmeowLogger struct {
@@ -82,47 +86,175 @@ func NewWhatsAppClient() error {
 		return fmt.Errorf("Could not initialize sqlstore for WhatsApp: %s", err)
 	}
 
-	deviceStore, err := container.GetFirstDevice()
-	if err != nil {
-		return fmt.Errorf("Could not initialize device store for WhatsApp: %s", err)
+	if err = state.State.LoadWhatsAppAccounts(); err != nil {
+		return err
 	}
+	var (
+		seenAccounts = make(map[string]bool)
+		// Commands in a Telegram chat act on the account bridged into it, so no chat
+		// can be shared between accounts
+		tgChatAccounts = make(map[int64]string)
+	)
+	for _, account := range state.State.WhatsAppAccounts {
+		if account.Name == "" || seenAccounts[account.Name] {
+			return fmt.Errorf("WhatsApp account names must be unique and non-empty, found '%s' more than once", account.Name)
+		}
+		if account.TargetChatID == 0 && account.Name != state.DefaultWhatsAppAccount {
+			return fmt.Errorf("WhatsApp account '%s' does not have a target_chat_id", account.Name)
+		}
+		seenAccounts[account.Name] = true
 
-	client := whatsmeow.NewClient(deviceStore, waClientLogger)
-	state.State.WhatsAppClient = client
+		tgChatIds := []int64{account.TargetChatID}
+		for _, route := range account.Routes {
+			tgChatIds = append(tgChatIds, route.TargetChatID)
+		}
+		for _, tgChatId := range tgChatIds {
+			if otherAccount, found := tgChatAccounts[tgChatId]; found && otherAccount != account.Name {
+				return fmt.Errorf("WhatsApp accounts '%s' and '%s' are both bridged into the Telegram chat %v, each account needs chats of its own",
+					otherAccount, account.Name, tgChatId)
+			}
+			if tgChatId != 0 {
+				tgChatAccounts[tgChatId] = account.Name
+			}
+		}
+	}
 
-	if client.Store.ID == nil {
-		qrChan, _ := client.GetQRChannel(context.Background())
-		err = client.Connect()
+	if _, err = utils.WaGetStatusAudience(); err != nil {
+		return fmt.Errorf("Invalid status_posting.audience in the config file: %s", err)
+	}
+
+	for _, account := range state.State.WhatsAppAccounts {
+		deviceStore, err := getAccountDevice(container, account.Name)
 		if err != nil {
-			return fmt.Errorf("Could not connect to WhatsApp for login: %s", err)
+			return fmt.Errorf("Could not initialize device store for WhatsApp account '%s': %s", account.Name, err)
+		}
+
+		client := whatsmeow.NewClient(deviceStore, waClientLogger.Sub(account.Name))
+		client.EnableAutoReconnect = false
+		// New devices get their stores when paired, they are wrapped on PairSuccess
+		utils.WaWrapContactStore(client)
+		account.Client = client
+		account.Connection.SetStatus(state.WaConnecting, 0)
+		supervisors[account.Name] = &accountSupervisor{}
+		if state.State.WhatsAppClient == nil {
+			state.State.WhatsAppClient = client
 		}
-		for evt := range qrChan {
-			if evt.Event == "code" {
-				if state.State.TelegramBot != nil {
-					state.State.TelegramBot.SendMessage(
-						state.State.Config.Telegram.OwnerID,
-						"Please check your terminal and scan the QR code to login to WhatsApp",
+
+		// The handler is added before connecting, so that the first Connected event is
+		// seen and the events of the accounts which are connected while the later ones
+		// wait for their QR codes are not lost
+		client.AddEventHandler(NewWhatsAppEventHandler(account))
+
+		if client.Store.ID == nil {
+			err = LoginWithQRCode(account)
+			if err != nil {
+				return err
+			}
+		} else {
+			err = client.Connect()
+			if err != nil {
+				return fmt.Errorf("Could not connect to WhatsApp account '%s': %s", account.Name, err)
+			}
+			if err = database.WaAccountDeviceSet(account.Name, client.Store.ID.String()); err != nil {
+				return fmt.Errorf("Could not save device of WhatsApp account '%s': %s", account.Name, err)
+			}
+		}
+
+		logger.Info("Successfully logged into WhatsApp",
+			zap.String("account", account.Name),
+			zap.String("push_name", client.Store.PushName),
+			zap.String("jid", client.Store.ID.String()),
+		)
+	}
+	return nil
+}
+
+// getAccountDevice returns the device saved for the account, or a new one if the
+// account has not logged in yet. Sessions from before multiple accounts were
+// supported are used for the default account.
+func getAccountDevice(container *sqlstore.Container, accountName string) (*store.Device, error) {
+	jidString, err := database.WaAccountDeviceGet(accountName)
+	if err != nil {
+		return nil, err
+	}
+
+	if jidString == "" {
+		if accountName == state.DefaultWhatsAppAccount {
+			return container.GetFirstDevice()
+		}
+		return container.NewDevice(), nil
+	}
+
+	jid, err := waTypes.ParseJID(jidString)
+	if err != nil {
+		return nil, err
+	}
+	deviceStore, err := container.GetDevice(jid)
+	if err != nil {
+		return nil, err
+	}
+	if deviceStore == nil {
+		// The session was deleted, probably after being logged out
+		return container.NewDevice(), nil
+	}
+	return deviceStore, nil
+}
+
+// LoginWithQRCode connects the account's client without any stored session and waits till
+// the QR code is scanned. The codes are printed in the terminal and also sent to the owner.
+func LoginWithQRCode(account *state.WhatsAppAccount) error {
+	var (
+		cfg    = state.State.Config
+		logger = state.State.Logger
+		tgBot  = state.State.TelegramBot
+		client = account.Client
+	)
+	defer logger.Sync()
+
+	qrChan, _ := client.GetQRChannel(context.Background())
+	err := client.Connect()
+	if err != nil {
+		return fmt.Errorf("Could not connect to WhatsApp account '%s' for login: %s", account.Name, err)
+	}
+	account.Connection.SetStatus(state.WaWaitingForQRLogin, 0)
+
+	var lastQRMsg *gotgbot.Message
+	for evt := range qrChan {
+		if evt.Event == "code" {
+			if tgBot != nil {
+				if lastQRMsg != nil {
+					tgBot.DeleteMessage(lastQRMsg.Chat.Id, lastQRMsg.MessageId, &gotgbot.DeleteMessageOpts{})
+					lastQRMsg = nil
+				}
+				if qrCode, err := qr.Encode(evt.Code, qr.L); err == nil {
+					lastQRMsg, _ = tgBot.SendPhoto(cfg.Telegram.OwnerID, qrCode.PNG(), &gotgbot.SendPhotoOpts{
+						Caption: fmt.Sprintf("Please scan this QR code (also printed in your terminal) to login to the WhatsApp account '%s'", account.Name),
+					})
+				} else {
+					tgBot.SendMessage(
+						cfg.Telegram.OwnerID,
+						fmt.Sprintf("Please check your terminal and scan the QR code to login to the WhatsApp account '%s'", account.Name),
 						&gotgbot.SendMessageOpts{},
 					)
 				}
-				qrterminal.Generate(evt.Code, qrterminal.L, os.Stdout)
-			} else {
-				logger.Info("Received WhatsApp login event",
-					zap.Any("event", evt.Event),
-				)
 			}
-		}
-	} else {
-		err = client.Connect()
-		if err != nil {
-			return fmt.Errorf("Could not connect to WhatsApp: %s", err)
+			fmt.Printf("QR code for the WhatsApp account '%s':\n", account.Name)
+			qrterminal.Generate(evt.Code, qrterminal.L, os.Stdout)
+		} else {
+			logger.Info("Received WhatsApp login event",
+				zap.Any("event", evt.Event),
+			)
 		}
 	}
 
-	logger.Info("Successfully logged into WhatsApp",
-		zap.String("push_name", client.Store.PushName),
-		zap.String("jid", client.Store.ID.String()),
-	)
+	if tgBot != nil && lastQRMsg != nil {
+		tgBot.DeleteMessage(lastQRMsg.Chat.Id, lastQRMsg.MessageId, &gotgbot.DeleteMessageOpts{})
+	}
 
-	return nil
+	if client.Store.ID == nil {
+		account.Connection.SetStatus(state.WaLoggedOut, 0)
+		return fmt.Errorf("Could not login to WhatsApp account '%s': QR code was not scanned in time", account.Name)
+	}
+
+	return database.WaAccountDeviceSet(account.Name, client.Store.ID.String())
 }
@@ -23,7 +23,14 @@ import (
 	"google.golang.org/protobuf/proto"
 )
 
-func WhatsAppEventHandler(evt interface{}) {
+// NewWhatsAppEventHandler returns the event handler for the client of the account
+func NewWhatsAppEventHandler(account *state.WhatsAppAccount) func(interface{}) {
+	return func(evt interface{}) {
+		WhatsAppEventHandler(account, evt)
+	}
+}
+
+func WhatsAppEventHandler(account *state.WhatsAppAccount, evt interface{}) {
 
 	var (
 		cfg    = state.State.Config
@@ -31,13 +38,48 @@ func WhatsAppEventHandler(evt interface{}) {
 	)
 	defer logger.Sync()
 
+	if !state.State.InFlight.Begin() {
+		logger.Warn("dropping whatsapp event as the bridge is shutting down",
+			zap.Any("event_type", reflect.TypeOf(evt)),
+		)
+		return
+	}
+	defer state.State.InFlight.Done()
+
 	switch v := evt.(type) {
 
 	case *events.PushName:
 		PushNameEventHandler(v)
 
 	case *events.CallOffer:
-		CallOfferEventHandler(v)
+		CallOfferEventHandler(account, v)
+
+	case *events.Connected:
+		ConnectedEventHandler(account, v)
+
+	case *events.Disconnected:
+		DisconnectedEventHandler(account, v)
+
+	case *events.KeepAliveTimeout:
+		KeepAliveTimeoutEventHandler(account, v)
+
+	case *events.StreamReplaced:
+		StreamReplacedEventHandler(account, v)
+
+	case *events.TemporaryBan:
+		TemporaryBanEventHandler(account, v)
+
+	case *events.LoggedOut:
+		LoggedOutEventHandler(account, v)
+
+	case *events.PairSuccess:
+		// The stores of new devices are only made once they are paired
+		utils.WaWrapContactStore(account.Client)
+
+	case *events.GroupInfo:
+		if v.Ephemeral != nil {
+			GroupDisappearingEventHandler(account, v)
+		}
 
 	case *events.Message:
 
@@ -45,6 +87,11 @@ func WhatsAppEventHandler(evt interface{}) {
 			zap.String("event_id", v.Info.ID),
 		)
 
+		editedMsgId := utils.WaNormalizeMessage(v)
+		if v.Message == nil {
+			return
+		}
+
 		if v.Info.Timestamp.UTC().Before(state.State.StartTime) {
 			// Old events
 			logger.Debug("returning due to message being older than bot start time",
@@ -64,7 +111,13 @@ func WhatsAppEventHandler(evt interface{}) {
 			logger.Debug("new revoked message",
 				zap.String("event_id", v.Info.ID),
 			)
-			RevokedMessageEventHandler(v)
+			RevokedMessageEventHandler(account, v)
+			return
+		} else if protoMsg != nil && protoMsg.GetType() == waProto.ProtocolMessage_EPHEMERAL_SETTING {
+			logger.Debug("new disappearing messages setting",
+				zap.String("event_id", v.Info.ID),
+			)
+			DisappearingSettingEventHandler(account, v.Info.Chat, protoMsg.GetEphemeralExpiration())
 			return
 		}
 
@@ -87,12 +140,12 @@ func WhatsAppEventHandler(evt interface{}) {
 			logger.Debug("new message from your account",
 				zap.String("event_id", v.Info.ID),
 			)
-			MessageFromMeEventHandler(text, v)
+			MessageFromMeEventHandler(account, text, editedMsgId, v)
 		} else {
 			logger.Debug("new message from others",
 				zap.String("event_id", v.Info.ID),
 			)
-			MessageFromOthersEventHandler(text, v)
+			MessageFromOthersEventHandler(account, text, editedMsgId, v)
 		}
 
 	default:
@@ -103,7 +156,7 @@ func WhatsAppEventHandler(evt interface{}) {
 
 }
 
-func MessageFromMeEventHandler(text string, v *events.Message) {
+func MessageFromMeEventHandler(account *state.WhatsAppAccount, text, editedMsgId string, v *events.Message) {
 	logger := state.State.Logger
 	defer logger.Sync()
 
@@ -112,7 +165,7 @@ func MessageFromMeEventHandler(text string, v *events.Message) {
 		logger.Debug("identified .id command",
 			zap.String("event_id", v.Info.ID),
 		)
-		waClient := state.State.WhatsAppClient
+		waClient := account.Client
 
 		_, err := waClient.SendMessage(context.Background(), v.Info.Chat, &waProto.Message{
 			ExtendedTextMessage: &waProto.ExtendedTextMessage{
@@ -140,27 +193,28 @@ func MessageFromMeEventHandler(text string, v *events.Message) {
 			zap.String("event_id", v.Info.ID),
 			zap.String("group_jid", v.Info.Chat.String()),
 		)
-		utils.WaTagAll(v.Info.Chat, v.Message, v.Info.ID, v.Info.MessageSource.Sender.String(), true)
+		utils.WaTagAll(account, v.Info.Chat, v.Message, v.Info.ID, v.Info.MessageSource.Sender.String(), true)
 	}
 
 	if state.State.Config.WhatsApp.SendMyMessagesFromOtherDevices {
-		MessageFromOthersEventHandler(text, v)
+		MessageFromOthersEventHandler(account, text, editedMsgId, v)
 	}
 }
 
-func MessageFromOthersEventHandler(text string, v *events.Message) {
+func MessageFromOthersEventHandler(account *state.WhatsAppAccount, text, editedMsgId string, v *events.Message) {
 	var (
-		cfg      = state.State.Config
-		logger   = state.State.Logger
-		tgBot    = state.State.TelegramBot
-		waClient = state.State.WhatsAppClient
+		cfg          = state.State.Config
+		logger       = state.State.Logger
+		tgBot        = state.State.TelegramBot
+		waClient     = account.Client
+		targetChatId = utils.WaGetTargetChat(account, v.Info.Chat, v.Info.MessageSource.Sender)
 	)
 	defer logger.Sync()
 
 	{
 		// Return if duplicate event is emitted
-		tgChatId, _, _, _ := database.MsgIdGetTgFromWa(v.Info.ID, v.Info.Chat.String())
-		if tgChatId == cfg.Telegram.TargetChatID {
+		tgChatId, _, _, _ := database.MsgIdGetTgFromWa(account.Name, v.Info.ID, v.Info.Chat.String())
+		if tgChatId == targetChatId {
 			logger.Debug("returning because duplicate event id emitted",
 				zap.String("event_id", v.Info.ID),
 				zap.String("chat_jid", v.Info.Chat.String()),
@@ -169,34 +223,138 @@ func MessageFromOthersEventHandler(text string, v *events.Message) {
 		}
 	}
 
+	// View once media is only bridged if allowed, as Telegram can merely discourage saving it
+	isViewOnce := v.IsViewOnce
+
+	// Settings of statuses and broadcasts are taken from the sender's chat, as they go in its topic
+	settingsChat := v.Info.Chat.ToNonAD()
+	if v.Info.IsIncomingBroadcast() {
+		settingsChat = v.Info.MessageSource.Sender.ToNonAD()
+	}
+	settings, err := utils.WaGetChatSettings(account, settingsChat)
+	if err != nil {
+		logger.Error("failed to get chat settings, using the defaults",
+			zap.String("event_id", v.Info.ID),
+			zap.String("chat_jid", settingsChat.String()),
+			zap.Error(err),
+		)
+	}
+
+	// Telegram messages sent for the message besides the one paired with it, like the
+	// header of video notes, the cards of contact arrays and the alert
+	var extraTgMsgIds []int64
+
+	if expiration := utils.WaGetMessageContextInfo(v.Message).GetExpiration(); expiration > 0 &&
+		v.Info.Chat != waTypes.StatusBroadcastJID {
+		// Messages carry the timer of their chat, which keeps the stored one up to date
+		if err := utils.WaUpdateDisappearingTimer(account, v.Info.Chat, expiration, false); err != nil {
+			logger.Warn("failed to update the disappearing messages timer",
+				zap.String("event_id", v.Info.ID),
+				zap.Error(err),
+			)
+		}
+		if cfg.WhatsApp.DeleteExpiredCopies {
+			defer func() {
+				copyMsgIds := extraTgMsgIds
+				tgChatId, _, tgMsgId, err := database.MsgIdGetTgFromWa(account.Name, v.Info.ID, v.Info.Chat.String())
+				if err == nil && tgChatId == targetChatId {
+					copyMsgIds = append(copyMsgIds, tgMsgId)
+				}
+				expiresAt := v.Info.Timestamp.Add(time.Duration(expiration) * time.Second)
+
+				addedTgMsgIds := make(map[int64]bool)
+				for _, copyMsgId := range copyMsgIds {
+					if copyMsgId == 0 || addedTgMsgIds[copyMsgId] {
+						continue
+					}
+					addedTgMsgIds[copyMsgId] = true
+
+					if err := database.ExpiringCopyAdd(targetChatId, copyMsgId, expiresAt); err != nil {
+						logger.Error("failed to add the bridged message to the expiring copies",
+							zap.String("event_id", v.Info.ID),
+							zap.Int64("tg_msg_id", copyMsgId),
+							zap.Error(err),
+						)
+					}
+				}
+			}()
+		}
+	}
+
 	if !v.Info.IsFromMe {
-		// Return if status is from ignored chat
-		if v.Info.Chat.String() == "status@broadcast" && slices.Contains(cfg.WhatsApp.StatusIgnoredChats, v.Info.MessageSource.Sender.User) {
-			logger.Debug("returning because status from a ignored chat",
+		// Alerts are checked even for the messages not bridged, and after the others so as to link to them
+		defer func() {
+			if alertMsgId := utils.TgSendAlerts(account, v, targetChatId); alertMsgId != 0 {
+				extraTgMsgIds = append(extraTgMsgIds, alertMsgId)
+			}
+		}()
+
+		// Return if status is from a muted chat
+		if v.Info.Chat == waTypes.StatusBroadcastJID &&
+			(settings.StatusMuted || slices.Contains(account.IgnoreChats, v.Info.Chat.User)) {
+			logger.Debug("returning because status from a muted chat",
+				zap.String("event_id", v.Info.ID),
+				zap.String("chat_jid", v.Info.Chat.String()),
+			)
+			return
+		} else if v.Info.Chat != waTypes.StatusBroadcastJID && settings.Muted &&
+			!(settings.MuteAllowMentions && utils.WaIsMentioned(account, v.Message)) {
+			if settings.MuteMode == utils.MuteModeHold {
+				logger.Debug("holding message from a muted chat for the digest",
+					zap.String("event_id", v.Info.ID),
+					zap.String("chat_jid", v.Info.Chat.String()),
+				)
+				if err := utils.WaHoldMessage(account, settingsChat.String(), v); err != nil {
+					logger.Error("failed to hold message from a muted chat",
+						zap.String("event_id", v.Info.ID),
+						zap.Error(err),
+					)
+				}
+				return
+			}
+			logger.Debug("returning because message from a muted chat",
 				zap.String("event_id", v.Info.ID),
 				zap.String("chat_jid", v.Info.Chat.String()),
 			)
 			return
-		} else if slices.Contains(cfg.WhatsApp.IgnoreChats, v.Info.Chat.User) {
-			logger.Debug("returning because message from an ignored chat",
+		} else if v.Info.Chat != waTypes.StatusBroadcastJID && settings.Digest {
+			logger.Debug("holding message from a chat in digest mode",
 				zap.String("event_id", v.Info.ID),
 				zap.String("chat_jid", v.Info.Chat.String()),
 			)
+			if err := utils.WaHoldMessage(account, settingsChat.String(), v); err != nil {
+				logger.Error("failed to hold message for the digest",
+					zap.String("event_id", v.Info.ID),
+					zap.Error(err),
+				)
+			}
 			return
 		}
+
+		if settings.ReadReceipts == utils.ReadReceiptsOnBridge {
+			defer func() {
+				err := waClient.MarkRead([]waTypes.MessageID{v.Info.ID}, time.Now(), v.Info.Chat, v.Info.MessageSource.Sender)
+				if err != nil {
+					logger.Warn("failed to mark the bridged message as read",
+						zap.String("event_id", v.Info.ID),
+						zap.Error(err),
+					)
+				}
+			}()
+		}
 	}
 	replymarkup := utils.TgBuildUrlButton(utils.WaGetContactName(v.Info.Sender), fmt.Sprintf("https://wa.me/%s", v.Info.MessageSource.Sender.ToNonAD().User))
-	if lowercaseText := strings.ToLower(text); !v.Info.IsFromMe && v.Info.IsGroup && slices.Contains(cfg.WhatsApp.TagAllAllowedGroups, v.Info.Chat.User) &&
+	if lowercaseText := strings.ToLower(text); !v.Info.IsFromMe && v.Info.IsGroup && slices.Contains(account.TagAllAllowedGroups, v.Info.Chat.User) &&
 		(strings.Contains(lowercaseText, "@all") || strings.Contains(lowercaseText, "@everyone")) {
 		logger.Debug("usage of @all/@everyone command from your account",
 			zap.String("event_id", v.Info.ID),
 			zap.String("chat_jid", v.Info.Chat.String()),
 		)
-		utils.WaTagAll(v.Info.Chat, v.Message, v.Info.ID, v.Info.MessageSource.Sender.String(), false)
+		utils.WaTagAll(account, v.Info.Chat, v.Message, v.Info.ID, v.Info.MessageSource.Sender.String(), false)
 	}
 
 	var bridgedText string
-	if cfg.WhatsApp.SkipChatDetails {
+	if settings.HeaderStyle == utils.HeaderStyleCompact {
 		logger.Debug("skipping to add chat details as configured",
 			zap.String("event_id", v.Info.ID),
 		)
@@ -218,13 +376,20 @@ func MessageFromOthersEventHandler(text string, v *events.Message) {
 		if v.Info.IsIncomingBroadcast() {
 			bridgedText += "<b>#Broadcast</b>\n"
 		} else if v.Info.IsGroup {
-			bridgedText += fmt.Sprintf("<b>%s</b>\n", html.EscapeString(utils.WaGetGroupName(v.Info.Chat)))
+			bridgedText += fmt.Sprintf("<b>%s</b>\n", html.EscapeString(utils.WaGetGroupName(account, v.Info.Chat)))
 		} else {
 			bridgedText += "<b>#Private</b>\n"
 		}
 
 	}
 
+	if isViewOnce {
+		bridgedText += "<b>#ViewOnce</b>\n"
+	}
+	if v.IsEdit {
+		bridgedText += "<b>#Edited</b>\n"
+	}
+
 	if time.Since(v.Info.Timestamp).Seconds() > 60 {
 		bridgedText += fmt.Sprintf("<b>%s</b>\n",
 			html.EscapeString(v.Info.Timestamp.In(state.State.LocalLocation).Format(cfg.TimeFormat)))
@@ -256,6 +421,11 @@ func MessageFromOthersEventHandler(text string, v *events.Message) {
 			zap.String("event_id", v.Info.ID),
 		)
 		contextInfo = v.Message.GetVideoMessage().GetContextInfo()
+	} else if v.Message.GetPtvMessage() != nil {
+		logger.Debug("taking context info from PtvMessage",
+			zap.String("event_id", v.Info.ID),
+		)
+		contextInfo = v.Message.GetPtvMessage().GetContextInfo()
 	} else if v.Message.GetAudioMessage() != nil {
 		logger.Debug("taking context info from AudioMessage",
 			zap.String("event_id", v.Info.ID),
@@ -324,19 +494,19 @@ func MessageFromOthersEventHandler(text string, v *events.Message) {
 		logger.Debug("checking if your account is mentioned in the message",
 			zap.String("event_id", v.Info.ID),
 		)
-		if mentioned := contextInfo.GetMentionedJid(); v.Info.IsGroup && mentioned != nil {
+		if mentioned := contextInfo.GetMentionedJid(); v.Info.IsGroup && settings.MentionAlerts && mentioned != nil {
 			for _, jid := range mentioned {
 				parsedJid, _ := utils.WaParseJID(jid)
 				if parsedJid.User == waClient.Store.ID.User {
 
 					tagInfoText := "<b>#Tags</b>\n" + bridgedText + fmt.Sprintf("<b>%s</b>",
-						html.EscapeString(utils.WaGetGroupName(v.Info.Chat)))
+						html.EscapeString(utils.WaGetGroupName(account, v.Info.Chat)))
 
-					threadId, err := utils.TgGetOrMakeThreadFromWa("status@broadcast", cfg.Telegram.TargetChatID, "Status/Calls/Tags [status@broadcast]")
+					threadId, err := utils.TgGetOrMakeSystemThread(account, utils.SystemTopicMentions, targetChatId)
 					if err != nil {
-						utils.TgSendErrorById(tgBot, cfg.Telegram.TargetChatID, 0, "failed to create/find thread id for 'status@broadcast'", err)
+						utils.TgSendBridgeError(account, targetChatId, "failed to create/find thread id for mentions", err)
 					} else {
-						tgBot.SendMessage(cfg.Telegram.TargetChatID, tagInfoText, &gotgbot.SendMessageOpts{
+						tgBot.SendMessage(targetChatId, tagInfoText, &gotgbot.SendMessageOpts{
 							MessageThreadId: threadId,
 							ReplyMarkup:     replymarkup,
 						})
@@ -351,8 +521,8 @@ func MessageFromOthersEventHandler(text string, v *events.Message) {
 			zap.String("event_id", v.Info.ID),
 		)
 		stanzaId := contextInfo.GetStanzaId()
-		tgChatId, tgThreadId, tgMsgId, err := database.MsgIdGetTgFromWa(stanzaId, v.Info.Chat.String())
-		if err == nil && tgChatId == cfg.Telegram.TargetChatID {
+		tgChatId, tgThreadId, tgMsgId, err := database.MsgIdGetTgFromWa(account.Name, stanzaId, v.Info.Chat.String())
+		if err == nil && tgChatId == targetChatId {
 			replyToMsgId = tgMsgId
 			threadId = tgThreadId
 			threadIdFound = true
@@ -361,21 +531,37 @@ func MessageFromOthersEventHandler(text string, v *events.Message) {
 		// Telegram will automatically trim the string
 		bridgedText += "\n"
 	}
+	if editedMsgId != "" {
+		// Edits are sent as replies to the message they change
+		tgChatId, tgThreadId, tgMsgId, err := database.MsgIdGetTgFromWa(account.Name, editedMsgId, v.Info.Chat.String())
+		if err == nil && tgChatId == targetChatId {
+			replyToMsgId = tgMsgId
+			threadId = tgThreadId
+			threadIdFound = true
+		}
+	}
 	if !threadIdFound {
 		var err error
-		if v.Info.IsIncomingBroadcast() {
-			threadId, err = utils.TgGetOrMakeThreadFromWa(v.Info.MessageSource.Sender.ToNonAD().String(), cfg.Telegram.TargetChatID,
+		if v.Info.Chat == waTypes.StatusBroadcastJID && !v.Info.IsFromMe && cfg.WhatsApp.StatusLayout == utils.StatusLayoutSingleTopic {
+			threadId, replyToMsgId, err = utils.TgGetOrMakeStatusHeader(account, v.Info.MessageSource.Sender, targetChatId)
+			if err != nil {
+				utils.TgSendBridgeError(account, targetChatId, fmt.Sprintf("failed to create/find status header for '%s'",
+					v.Info.MessageSource.Sender.ToNonAD().String()), err)
+				return
+			}
+		} else if v.Info.IsIncomingBroadcast() {
+			threadId, err = utils.TgGetOrMakeThreadFromWa(account, v.Info.MessageSource.Sender.ToNonAD().String(), targetChatId,
 				utils.WaGetContactName(v.Info.MessageSource.Sender))
 			if err != nil {
-				utils.TgSendErrorById(tgBot, cfg.Telegram.TargetChatID, 0, fmt.Sprintf("failed to create/find thread id for '%s'",
+				utils.TgSendBridgeError(account, targetChatId, fmt.Sprintf("failed to create/find thread id for '%s'",
 					v.Info.MessageSource.Sender.ToNonAD().String()), err)
 				return
 			}
 		} else if v.Info.IsGroup {
-			threadId, err = utils.TgGetOrMakeThreadFromWa(v.Info.Chat.String(), cfg.Telegram.TargetChatID,
-				utils.WaGetGroupName(v.Info.Chat))
+			threadId, err = utils.TgGetOrMakeThreadFromWa(account, v.Info.Chat.String(), targetChatId,
+				utils.WaGetGroupName(account, v.Info.Chat))
 			if err != nil {
-				utils.TgSendErrorById(tgBot, cfg.Telegram.TargetChatID, 0, fmt.Sprintf("failed to create/find thread id for '%s'",
+				utils.TgSendBridgeError(account, targetChatId, fmt.Sprintf("failed to create/find thread id for '%s'",
 					v.Info.Chat.String()), err)
 				return
 			}
@@ -387,55 +573,68 @@ func MessageFromOthersEventHandler(text string, v *events.Message) {
 				target_chat_jid = v.Info.Chat
 			}
 
-			threadId, err = utils.TgGetOrMakeThreadFromWa(target_chat_jid.ToNonAD().String(), cfg.Telegram.TargetChatID, utils.WaGetContactName(target_chat_jid))
+			threadId, err = utils.TgGetOrMakeThreadFromWa(account, target_chat_jid.ToNonAD().String(), targetChatId, utils.WaGetContactName(target_chat_jid))
 			if err != nil {
-				utils.TgSendErrorById(tgBot, cfg.Telegram.TargetChatID, 0, fmt.Sprintf("failed to create/find thread id for '%s'",
+				utils.TgSendBridgeError(account, targetChatId, fmt.Sprintf("failed to create/find thread id for '%s'",
 					target_chat_jid.ToNonAD().String()), err)
 				return
 			}
 		}
 	}
 
-	if v.Message.GetImageMessage() != nil {
+	if isViewOnce && !cfg.WhatsApp.BridgeViewOnce {
+
+		bridgedText += "\n<b>Received view once media, which is not bridged as 'bridge_view_once' is not set</b>"
+		sentMsg, _ := tgBot.SendMessage(targetChatId, bridgedText, &gotgbot.SendMessageOpts{
+			ReplyToMessageId: replyToMsgId,
+			MessageThreadId:  threadId,
+		})
+		if sentMsg.MessageId != 0 {
+			database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+				targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
+		}
+		return
+
+	} else if v.Message.GetImageMessage() != nil {
 
 		imageMsg := v.Message.GetImageMessage()
 		if imageMsg.GetUrl() == "" {
 			return
 		}
 
-		if cfg.WhatsApp.SkipImages {
-			bridgedText += "\n<b>Skipping image because 'skip_images' set in config file</b>"
-			sentMsg, _ := tgBot.SendMessage(cfg.Telegram.TargetChatID, bridgedText, &gotgbot.SendMessageOpts{
+		if settings.SkipImages {
+			bridgedText += "\n<b>Skipping image because 'skip_images' is set for this chat</b>"
+			sentMsg, _ := tgBot.SendMessage(targetChatId, bridgedText, &gotgbot.SendMessageOpts{
 				ReplyToMessageId: replyToMsgId,
 				MessageThreadId:  threadId,
 			})
 			if sentMsg.MessageId != 0 {
-				database.MsgIdAddNewPair(v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
-					cfg.Telegram.TargetChatID, sentMsg.MessageId, sentMsg.MessageThreadId)
+				database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+					targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
 			}
 			return
 		} else if !cfg.Telegram.SelfHostedAPI && imageMsg.GetFileLength() > utils.UploadSizeLimit {
 			bridgedText += "\n<b>Couldn't send the photo as it exceeds Telegram size restrictions</b>"
-			sentMsg, _ := tgBot.SendMessage(cfg.Telegram.TargetChatID, bridgedText, &gotgbot.SendMessageOpts{
+			sentMsg, _ := tgBot.SendMessage(targetChatId, bridgedText, &gotgbot.SendMessageOpts{
 				ReplyToMessageId: replyToMsgId,
 				MessageThreadId:  threadId,
 			})
 			if sentMsg.MessageId != 0 {
-				database.MsgIdAddNewPair(v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
-					cfg.Telegram.TargetChatID, sentMsg.MessageId, sentMsg.MessageThreadId)
+				database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+					targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
 			}
 			return
 		} else {
 			imageBytes, err := waClient.Download(imageMsg)
 			if err != nil {
 				bridgedText += "\n<b>Couldn't download the photo due to some errors</b>"
-				sentMsg, _ := tgBot.SendMessage(cfg.Telegram.TargetChatID, bridgedText, &gotgbot.SendMessageOpts{
+				sentMsg, _ := tgBot.SendMessage(targetChatId, bridgedText, &gotgbot.SendMessageOpts{
 					ReplyToMessageId: replyToMsgId,
 					MessageThreadId:  threadId,
 				})
 				if sentMsg.MessageId != 0 {
-					database.MsgIdAddNewPair(v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
-						cfg.Telegram.TargetChatID, sentMsg.MessageId, sentMsg.MessageThreadId)
+					database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+						targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
 				}
 				return
 			}
@@ -448,14 +647,16 @@ func MessageFromOthersEventHandler(text string, v *events.Message) {
 				}
 			}
 
-			sentMsg, _ := tgBot.SendPhoto(cfg.Telegram.TargetChatID, imageBytes, &gotgbot.SendPhotoOpts{
+			sentMsg, _ := tgBot.SendPhoto(targetChatId, imageBytes, &gotgbot.SendPhotoOpts{
 				Caption:          bridgedText,
 				ReplyToMessageId: replyToMsgId,
 				MessageThreadId:  threadId,
+				HasSpoiler:       isViewOnce,
+				ProtectContent:   isViewOnce,
 			})
 			if sentMsg.MessageId != 0 {
-				database.MsgIdAddNewPair(v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
-					cfg.Telegram.TargetChatID, sentMsg.MessageId, sentMsg.MessageThreadId)
+				database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+					targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
 			}
 			return
 		}
@@ -467,39 +668,39 @@ func MessageFromOthersEventHandler(text string, v *events.Message) {
 			return
 		}
 
-		if cfg.WhatsApp.SkipGIFs {
-			bridgedText += "\n<b>Skipping GIF because 'skip_gifs' set in config file</b>"
-			sentMsg, _ := tgBot.SendMessage(cfg.Telegram.TargetChatID, bridgedText, &gotgbot.SendMessageOpts{
+		if settings.SkipGIFs {
+			bridgedText += "\n<b>Skipping GIF because 'skip_gifs' is set for this chat</b>"
+			sentMsg, _ := tgBot.SendMessage(targetChatId, bridgedText, &gotgbot.SendMessageOpts{
 				ReplyToMessageId: replyToMsgId,
 				MessageThreadId:  threadId,
 			})
 			if sentMsg.MessageId != 0 {
-				database.MsgIdAddNewPair(v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
-					cfg.Telegram.TargetChatID, sentMsg.MessageId, sentMsg.MessageThreadId)
+				database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+					targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
 			}
 			return
 		} else if !cfg.Telegram.SelfHostedAPI && gifMsg.GetFileLength() > utils.UploadSizeLimit {
 			bridgedText += "\n<b>Couldn't send the GIF as it exceeds Telegram size restrictions</b>"
-			sentMsg, _ := tgBot.SendMessage(cfg.Telegram.TargetChatID, bridgedText, &gotgbot.SendMessageOpts{
+			sentMsg, _ := tgBot.SendMessage(targetChatId, bridgedText, &gotgbot.SendMessageOpts{
 				ReplyToMessageId: replyToMsgId,
 				MessageThreadId:  threadId,
 			})
 			if sentMsg.MessageId != 0 {
-				database.MsgIdAddNewPair(v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
-					cfg.Telegram.TargetChatID, sentMsg.MessageId, sentMsg.MessageThreadId)
+				database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+					targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
 			}
 			return
 		} else {
 			gifBytes, err := waClient.Download(gifMsg)
 			if err != nil {
 				bridgedText += "\n<b>Couldn't download the GIF due to some errors</b>"
-				sentMsg, _ := tgBot.SendMessage(cfg.Telegram.TargetChatID, bridgedText, &gotgbot.SendMessageOpts{
+				sentMsg, _ := tgBot.SendMessage(targetChatId, bridgedText, &gotgbot.SendMessageOpts{
 					ReplyToMessageId: replyToMsgId,
 					MessageThreadId:  threadId,
 				})
 				if sentMsg.MessageId != 0 {
-					database.MsgIdAddNewPair(v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
-						cfg.Telegram.TargetChatID, sentMsg.MessageId, sentMsg.MessageThreadId)
+					database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+						targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
 				}
 				return
 			}
@@ -513,18 +714,20 @@ func MessageFromOthersEventHandler(text string, v *events.Message) {
 			}
 
 			fileToSend := gotgbot.NamedFile{
-				FileName: "animation.gif",
+				FileName: utils.MakeFileName("", "animation", utils.DetectMimeType(gifBytes, gifMsg.GetMimetype())),
 				File:     bytes.NewReader(gifBytes),
 			}
 
-			sentMsg, _ := tgBot.SendAnimation(cfg.Telegram.TargetChatID, fileToSend, &gotgbot.SendAnimationOpts{
+			sentMsg, _ := tgBot.SendAnimation(targetChatId, fileToSend, &gotgbot.SendAnimationOpts{
 				Caption:          bridgedText,
 				ReplyToMessageId: replyToMsgId,
 				MessageThreadId:  threadId,
+				HasSpoiler:       isViewOnce,
+				ProtectContent:   isViewOnce,
 			})
 			if sentMsg.MessageId != 0 {
-				database.MsgIdAddNewPair(v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
-					cfg.Telegram.TargetChatID, sentMsg.MessageId, sentMsg.MessageThreadId)
+				database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+					targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
 			}
 			return
 		}
@@ -536,39 +739,39 @@ func MessageFromOthersEventHandler(text string, v *events.Message) {
 			return
 		}
 
-		if cfg.WhatsApp.SkipVideos {
-			bridgedText += "\n<b>Skipping video because 'skip_videos' set in config file</b>"
-			sentMsg, _ := tgBot.SendMessage(cfg.Telegram.TargetChatID, bridgedText, &gotgbot.SendMessageOpts{
+		if settings.SkipVideos {
+			bridgedText += "\n<b>Skipping video because 'skip_videos' is set for this chat</b>"
+			sentMsg, _ := tgBot.SendMessage(targetChatId, bridgedText, &gotgbot.SendMessageOpts{
 				ReplyToMessageId: replyToMsgId,
 				MessageThreadId:  threadId,
 			})
 			if sentMsg.MessageId != 0 {
-				database.MsgIdAddNewPair(v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
-					cfg.Telegram.TargetChatID, sentMsg.MessageId, sentMsg.MessageThreadId)
+				database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+					targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
 			}
 			return
 		} else if !cfg.Telegram.SelfHostedAPI && videoMsg.GetFileLength() > utils.UploadSizeLimit {
 			bridgedText += "\n<b>Couldn't send the video as it exceeds Telegram size restrictions</b>"
-			sentMsg, _ := tgBot.SendMessage(cfg.Telegram.TargetChatID, bridgedText, &gotgbot.SendMessageOpts{
+			sentMsg, _ := tgBot.SendMessage(targetChatId, bridgedText, &gotgbot.SendMessageOpts{
 				ReplyToMessageId: replyToMsgId,
 				MessageThreadId:  threadId,
 			})
 			if sentMsg.MessageId != 0 {
-				database.MsgIdAddNewPair(v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
-					cfg.Telegram.TargetChatID, sentMsg.MessageId, sentMsg.MessageThreadId)
+				database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+					targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
 			}
 			return
 		} else {
 			videoBytes, err := waClient.Download(videoMsg)
 			if err != nil {
 				bridgedText += "\n<b>Couldn't download the video due to some errors</b>"
-				sentMsg, _ := tgBot.SendMessage(cfg.Telegram.TargetChatID, bridgedText, &gotgbot.SendMessageOpts{
+				sentMsg, _ := tgBot.SendMessage(targetChatId, bridgedText, &gotgbot.SendMessageOpts{
 					ReplyToMessageId: replyToMsgId,
 					MessageThreadId:  threadId,
 				})
 				if sentMsg.MessageId != 0 {
-					database.MsgIdAddNewPair(v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
-						cfg.Telegram.TargetChatID, sentMsg.MessageId, sentMsg.MessageThreadId)
+					database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+						targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
 				}
 				return
 			}
@@ -582,18 +785,129 @@ func MessageFromOthersEventHandler(text string, v *events.Message) {
 			}
 
 			fileToSend := gotgbot.NamedFile{
-				FileName: "video." + strings.Split(videoMsg.GetMimetype(), "/")[1],
+				FileName: utils.MakeFileName("", "video", utils.DetectMimeType(videoBytes, videoMsg.GetMimetype())),
 				File:     bytes.NewReader(videoBytes),
 			}
 
-			sentMsg, _ := tgBot.SendVideo(cfg.Telegram.TargetChatID, fileToSend, &gotgbot.SendVideoOpts{
+			sentMsg, _ := tgBot.SendVideo(targetChatId, fileToSend, &gotgbot.SendVideoOpts{
 				Caption:          bridgedText,
 				ReplyToMessageId: replyToMsgId,
 				MessageThreadId:  threadId,
+				HasSpoiler:       isViewOnce,
+				ProtectContent:   isViewOnce,
+			})
+			if sentMsg.MessageId != 0 {
+				database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+					targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
+			}
+			return
+		}
+
+	} else if v.Message.GetPtvMessage() != nil {
+
+		videoNoteMsg := v.Message.GetPtvMessage()
+		if videoNoteMsg.GetUrl() == "" {
+			return
+		}
+
+		if settings.SkipVideos {
+			bridgedText += "\n<b>Skipping video note because 'skip_videos' is set for this chat</b>"
+			sentMsg, _ := tgBot.SendMessage(targetChatId, bridgedText, &gotgbot.SendMessageOpts{
+				ReplyToMessageId: replyToMsgId,
+				MessageThreadId:  threadId,
+			})
+			if sentMsg.MessageId != 0 {
+				database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+					targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
+			}
+			return
+		} else if !cfg.Telegram.SelfHostedAPI && videoNoteMsg.GetFileLength() > utils.UploadSizeLimit {
+			bridgedText += "\n<b>Couldn't send the video note as it exceeds Telegram size restrictions</b>"
+			sentMsg, _ := tgBot.SendMessage(targetChatId, bridgedText, &gotgbot.SendMessageOpts{
+				ReplyToMessageId: replyToMsgId,
+				MessageThreadId:  threadId,
 			})
 			if sentMsg.MessageId != 0 {
-				database.MsgIdAddNewPair(v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
-					cfg.Telegram.TargetChatID, sentMsg.MessageId, sentMsg.MessageThreadId)
+				database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+					targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
+			}
+			return
+		} else {
+			videoNoteBytes, err := waClient.Download(videoNoteMsg)
+			if err != nil {
+				bridgedText += "\n<b>Couldn't download the video note due to some errors</b>"
+				sentMsg, _ := tgBot.SendMessage(targetChatId, bridgedText, &gotgbot.SendMessageOpts{
+					ReplyToMessageId: replyToMsgId,
+					MessageThreadId:  threadId,
+				})
+				if sentMsg.MessageId != 0 {
+					database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+						targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
+				}
+				return
+			}
+			videoNoteBytes = utils.ConvertVideoNote(videoNoteBytes)
+
+			videoNoteLength := int64(videoNoteMsg.GetWidth())
+			if height := int64(videoNoteMsg.GetHeight()); height < videoNoteLength {
+				videoNoteLength = height
+			}
+			if videoNoteLength > utils.VideoNoteMaxLength {
+				videoNoteLength = utils.VideoNoteMaxLength
+			}
+			videoNoteSeconds := int64(videoNoteMsg.GetSeconds())
+			if videoNoteSeconds > utils.VideoNoteMaxSeconds {
+				videoNoteSeconds = utils.VideoNoteMaxSeconds
+			}
+
+			// Video notes cannot have captions, so the header is sent before the video note,
+			// which replies to it
+			if strings.TrimSpace(bridgedText) != "" {
+				headerMsg, err := tgBot.SendMessage(targetChatId, bridgedText, &gotgbot.SendMessageOpts{
+					ReplyToMessageId: replyToMsgId,
+					MessageThreadId:  threadId,
+				})
+				if err == nil {
+					replyToMsgId = headerMsg.MessageId
+					extraTgMsgIds = append(extraTgMsgIds, headerMsg.MessageId)
+				} else {
+					logger.Warn("failed to send header of video note",
+						zap.String("event_id", v.Info.ID),
+						zap.Error(err),
+					)
+				}
+			}
+
+			sentMsg, err := tgBot.SendVideoNote(targetChatId, gotgbot.NamedFile{
+				FileName: "video_note.mp4",
+				File:     bytes.NewReader(videoNoteBytes),
+			}, &gotgbot.SendVideoNoteOpts{
+				Duration:         videoNoteSeconds,
+				Length:           videoNoteLength,
+				ReplyToMessageId: replyToMsgId,
+				MessageThreadId:  threadId,
+				ProtectContent:   isViewOnce,
+				ReplyMarkup:      replymarkup,
+			})
+			if err != nil {
+				// Telegram refuses video notes which are not square, those are sent as videos
+				logger.Warn("failed to send video note, sending it as a video",
+					zap.String("event_id", v.Info.ID),
+					zap.Error(err),
+				)
+				sentMsg, _ = tgBot.SendVideo(targetChatId, gotgbot.NamedFile{
+					FileName: "video_note.mp4",
+					File:     bytes.NewReader(videoNoteBytes),
+				}, &gotgbot.SendVideoOpts{
+					ReplyToMessageId: replyToMsgId,
+					MessageThreadId:  threadId,
+					ProtectContent:   isViewOnce,
+					ReplyMarkup:      replymarkup,
+				})
+			}
+			if sentMsg != nil && sentMsg.MessageId != 0 {
+				database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+					targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
 			}
 			return
 		}
@@ -605,57 +919,58 @@ func MessageFromOthersEventHandler(text string, v *events.Message) {
 			return
 		}
 
-		if cfg.WhatsApp.SkipVoiceNotes {
-			bridgedText += "\n<b>Skipping voice note because 'skip_voice_notes' set in config file</b>"
-			sentMsg, _ := tgBot.SendMessage(cfg.Telegram.TargetChatID, bridgedText, &gotgbot.SendMessageOpts{
+		if settings.SkipVoiceNotes {
+			bridgedText += "\n<b>Skipping voice note because 'skip_voice_notes' is set for this chat</b>"
+			sentMsg, _ := tgBot.SendMessage(targetChatId, bridgedText, &gotgbot.SendMessageOpts{
 				ReplyToMessageId: replyToMsgId,
 				MessageThreadId:  threadId,
 			})
 			if sentMsg.MessageId != 0 {
-				database.MsgIdAddNewPair(v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
-					cfg.Telegram.TargetChatID, sentMsg.MessageId, sentMsg.MessageThreadId)
+				database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+					targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
 			}
 			return
 		} else if !cfg.Telegram.SelfHostedAPI && audioMsg.GetFileLength() > utils.UploadSizeLimit {
 			bridgedText += "\n<b>Couldn't send the audio as it exceeds Telegram size restrictions</b>"
-			sentMsg, _ := tgBot.SendMessage(cfg.Telegram.TargetChatID, bridgedText, &gotgbot.SendMessageOpts{
+			sentMsg, _ := tgBot.SendMessage(targetChatId, bridgedText, &gotgbot.SendMessageOpts{
 				ReplyToMessageId: replyToMsgId,
 				MessageThreadId:  threadId,
 			})
 			if sentMsg.MessageId != 0 {
-				database.MsgIdAddNewPair(v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
-					cfg.Telegram.TargetChatID, sentMsg.MessageId, sentMsg.MessageThreadId)
+				database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+					targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
 			}
 			return
 		} else {
 			audioBytes, err := waClient.Download(audioMsg)
 			if err != nil {
 				bridgedText += "\n<b>Couldn't download the audio due to some errors</b>"
-				sentMsg, _ := tgBot.SendMessage(cfg.Telegram.TargetChatID, bridgedText, &gotgbot.SendMessageOpts{
+				sentMsg, _ := tgBot.SendMessage(targetChatId, bridgedText, &gotgbot.SendMessageOpts{
 					ReplyToMessageId: replyToMsgId,
 					MessageThreadId:  threadId,
 				})
 				if sentMsg.MessageId != 0 {
-					database.MsgIdAddNewPair(v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
-						cfg.Telegram.TargetChatID, sentMsg.MessageId, sentMsg.MessageThreadId)
+					database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+						targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
 				}
 				return
 			}
 
 			fileToSend := gotgbot.NamedFile{
-				FileName: "audio.ogg",
+				FileName: utils.MakeFileName("", "voice", utils.DetectMimeType(audioBytes, audioMsg.GetMimetype())),
 				File:     bytes.NewReader(audioBytes),
 			}
 
-			sentMsg, _ := tgBot.SendAudio(cfg.Telegram.TargetChatID, fileToSend, &gotgbot.SendAudioOpts{
+			sentMsg, _ := tgBot.SendAudio(targetChatId, fileToSend, &gotgbot.SendAudioOpts{
 				Caption:          bridgedText,
 				Duration:         int64(audioMsg.GetSeconds()),
 				ReplyToMessageId: replyToMsgId,
 				MessageThreadId:  threadId,
+				ProtectContent:   isViewOnce,
 			})
 			if sentMsg.MessageId != 0 {
-				database.MsgIdAddNewPair(v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
-					cfg.Telegram.TargetChatID, sentMsg.MessageId, sentMsg.MessageThreadId)
+				database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+					targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
 			}
 			return
 		}
@@ -667,57 +982,58 @@ func MessageFromOthersEventHandler(text string, v *events.Message) {
 			return
 		}
 
-		if cfg.WhatsApp.SkipAudios {
-			bridgedText += "\n<b>Skipping audio because 'skip_audios' set in config file</b>"
-			sentMsg, _ := tgBot.SendMessage(cfg.Telegram.TargetChatID, bridgedText, &gotgbot.SendMessageOpts{
+		if settings.SkipAudios {
+			bridgedText += "\n<b>Skipping audio because 'skip_audios' is set for this chat</b>"
+			sentMsg, _ := tgBot.SendMessage(targetChatId, bridgedText, &gotgbot.SendMessageOpts{
 				ReplyToMessageId: replyToMsgId,
 				MessageThreadId:  threadId,
 			})
 			if sentMsg.MessageId != 0 {
-				database.MsgIdAddNewPair(v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
-					cfg.Telegram.TargetChatID, sentMsg.MessageId, sentMsg.MessageThreadId)
+				database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+					targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
 			}
 			return
 		} else if !cfg.Telegram.SelfHostedAPI && audioMsg.GetFileLength() > utils.UploadSizeLimit {
 			bridgedText += "\n<b>Couldn't send the audio as it exceeds Telegram size restrictions</b>"
-			sentMsg, _ := tgBot.SendMessage(cfg.Telegram.TargetChatID, bridgedText, &gotgbot.SendMessageOpts{
+			sentMsg, _ := tgBot.SendMessage(targetChatId, bridgedText, &gotgbot.SendMessageOpts{
 				ReplyToMessageId: replyToMsgId,
 				MessageThreadId:  threadId,
 			})
 			if sentMsg.MessageId != 0 {
-				database.MsgIdAddNewPair(v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
-					cfg.Telegram.TargetChatID, sentMsg.MessageId, sentMsg.MessageThreadId)
+				database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+					targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
 			}
 			return
 		} else {
 			audioBytes, err := waClient.Download(audioMsg)
 			if err != nil {
 				bridgedText += "\n<b>Couldn't download the audio due to some errors</b>"
-				sentMsg, _ := tgBot.SendMessage(cfg.Telegram.TargetChatID, bridgedText, &gotgbot.SendMessageOpts{
+				sentMsg, _ := tgBot.SendMessage(targetChatId, bridgedText, &gotgbot.SendMessageOpts{
 					ReplyToMessageId: replyToMsgId,
 					MessageThreadId:  threadId,
 				})
 				if sentMsg.MessageId != 0 {
-					database.MsgIdAddNewPair(v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
-						cfg.Telegram.TargetChatID, sentMsg.MessageId, sentMsg.MessageThreadId)
+					database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+						targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
 				}
 				return
 			}
 
 			fileToSend := gotgbot.NamedFile{
-				FileName: "audio.m4a",
+				FileName: utils.MakeFileName("", "audio", utils.DetectMimeType(audioBytes, audioMsg.GetMimetype())),
 				File:     bytes.NewReader(audioBytes),
 			}
 
-			sentMsg, _ := tgBot.SendAudio(cfg.Telegram.TargetChatID, fileToSend, &gotgbot.SendAudioOpts{
+			sentMsg, _ := tgBot.SendAudio(targetChatId, fileToSend, &gotgbot.SendAudioOpts{
 				Caption:          bridgedText,
 				Duration:         int64(audioMsg.GetSeconds()),
 				ReplyToMessageId: replyToMsgId,
 				MessageThreadId:  threadId,
+				ProtectContent:   isViewOnce,
 			})
 			if sentMsg.MessageId != 0 {
-				database.MsgIdAddNewPair(v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
-					cfg.Telegram.TargetChatID, sentMsg.MessageId, sentMsg.MessageThreadId)
+				database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+					targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
 			}
 			return
 		}
@@ -729,39 +1045,39 @@ func MessageFromOthersEventHandler(text string, v *events.Message) {
 			return
 		}
 
-		if cfg.WhatsApp.SkipDocuments {
-			bridgedText += "\n<b>Skipping document because 'skip_documents' set in config file</b>"
-			sentMsg, _ := tgBot.SendMessage(cfg.Telegram.TargetChatID, bridgedText, &gotgbot.SendMessageOpts{
+		if settings.SkipDocuments {
+			bridgedText += "\n<b>Skipping document because 'skip_documents' is set for this chat</b>"
+			sentMsg, _ := tgBot.SendMessage(targetChatId, bridgedText, &gotgbot.SendMessageOpts{
 				ReplyToMessageId: replyToMsgId,
 				MessageThreadId:  threadId,
 			})
 			if sentMsg.MessageId != 0 {
-				database.MsgIdAddNewPair(v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
-					cfg.Telegram.TargetChatID, sentMsg.MessageId, sentMsg.MessageThreadId)
+				database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+					targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
 			}
 			return
 		} else if !cfg.Telegram.SelfHostedAPI && documentMsg.GetFileLength() > utils.UploadSizeLimit {
 			bridgedText += "\n<b>Couldn't send the document as it exceeds Telegram size restrictions</b>"
-			sentMsg, _ := tgBot.SendMessage(cfg.Telegram.TargetChatID, bridgedText, &gotgbot.SendMessageOpts{
+			sentMsg, _ := tgBot.SendMessage(targetChatId, bridgedText, &gotgbot.SendMessageOpts{
 				ReplyToMessageId: replyToMsgId,
 				MessageThreadId:  threadId,
 			})
 			if sentMsg.MessageId != 0 {
-				database.MsgIdAddNewPair(v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
-					cfg.Telegram.TargetChatID, sentMsg.MessageId, sentMsg.MessageThreadId)
+				database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+					targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
 			}
 			return
 		} else {
 			documentBytes, err := waClient.Download(documentMsg)
 			if err != nil {
 				bridgedText += "\n<b>Couldn't download the document due to some errors</b>"
-				sentMsg, _ := tgBot.SendMessage(cfg.Telegram.TargetChatID, bridgedText, &gotgbot.SendMessageOpts{
+				sentMsg, _ := tgBot.SendMessage(targetChatId, bridgedText, &gotgbot.SendMessageOpts{
 					ReplyToMessageId: replyToMsgId,
 					MessageThreadId:  threadId,
 				})
 				if sentMsg.MessageId != 0 {
-					database.MsgIdAddNewPair(v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
-						cfg.Telegram.TargetChatID, sentMsg.MessageId, sentMsg.MessageThreadId)
+					database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+						targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
 				}
 				return
 			}
@@ -774,19 +1090,25 @@ func MessageFromOthersEventHandler(text string, v *events.Message) {
 				}
 			}
 
+			// Documents sent from some clients only have a title
+			documentFileName := documentMsg.GetFileName()
+			if documentFileName == "" {
+				documentFileName = documentMsg.GetTitle()
+			}
+
 			fileToSend := gotgbot.NamedFile{
-				FileName: documentMsg.GetFileName(),
+				FileName: utils.MakeFileName(documentFileName, "document", utils.DetectMimeType(documentBytes, documentMsg.GetMimetype())),
 				File:     bytes.NewReader(documentBytes),
 			}
 
-			sentMsg, _ := tgBot.SendDocument(cfg.Telegram.TargetChatID, fileToSend, &gotgbot.SendDocumentOpts{
+			sentMsg, _ := tgBot.SendDocument(targetChatId, fileToSend, &gotgbot.SendDocumentOpts{
 				Caption:          bridgedText,
 				ReplyToMessageId: replyToMsgId,
 				MessageThreadId:  threadId,
 			})
 			if sentMsg.MessageId != 0 {
-				database.MsgIdAddNewPair(v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
-					cfg.Telegram.TargetChatID, sentMsg.MessageId, sentMsg.MessageThreadId)
+				database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+					targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
 			}
 			return
 		}
@@ -798,44 +1120,69 @@ func MessageFromOthersEventHandler(text string, v *events.Message) {
 			return
 		}
 
-		if cfg.WhatsApp.SkipStickers {
-			bridgedText += "\n<b>Skipping sticker because 'skip_stickers' set in config file</b>"
-			sentMsg, _ := tgBot.SendMessage(cfg.Telegram.TargetChatID, bridgedText, &gotgbot.SendMessageOpts{
+		if settings.SkipStickers {
+			bridgedText += "\n<b>Skipping sticker because 'skip_stickers' is set for this chat</b>"
+			sentMsg, _ := tgBot.SendMessage(targetChatId, bridgedText, &gotgbot.SendMessageOpts{
 				ReplyToMessageId: replyToMsgId,
 				MessageThreadId:  threadId,
 			})
 			if sentMsg.MessageId != 0 {
-				database.MsgIdAddNewPair(v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
-					cfg.Telegram.TargetChatID, sentMsg.MessageId, sentMsg.MessageThreadId)
+				database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+					targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
 			}
 			return
 		} else if !cfg.Telegram.SelfHostedAPI && stickerMsg.GetFileLength() > utils.UploadSizeLimit {
 			bridgedText += "\n<b>Couldn't send the sticker as it exceeds Telegram size restrictions</b>"
-			sentMsg, _ := tgBot.SendMessage(cfg.Telegram.TargetChatID, bridgedText, &gotgbot.SendMessageOpts{
+			sentMsg, _ := tgBot.SendMessage(targetChatId, bridgedText, &gotgbot.SendMessageOpts{
 				ReplyToMessageId: replyToMsgId,
 				MessageThreadId:  threadId,
 			})
 			if sentMsg.MessageId != 0 {
-				database.MsgIdAddNewPair(v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
-					cfg.Telegram.TargetChatID, sentMsg.MessageId, sentMsg.MessageThreadId)
+				database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+					targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
 			}
 			return
 		} else {
 			stickerBytes, err := waClient.Download(stickerMsg)
 			if err != nil {
 				bridgedText += "\n<b>Couldn't download the sticker due to some errors</b>"
-				sentMsg, _ := tgBot.SendMessage(cfg.Telegram.TargetChatID, bridgedText, &gotgbot.SendMessageOpts{
+				sentMsg, _ := tgBot.SendMessage(targetChatId, bridgedText, &gotgbot.SendMessageOpts{
 					ReplyToMessageId: replyToMsgId,
 					MessageThreadId:  threadId,
 				})
 				if sentMsg.MessageId != 0 {
-					database.MsgIdAddNewPair(v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
-						cfg.Telegram.TargetChatID, sentMsg.MessageId, sentMsg.MessageThreadId)
+					database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+						targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
 				}
 				return
 			}
 			if stickerMsg.GetIsAnimated() || stickerMsg.GetIsAvatar() {
-				gifBytes, err := utils.AnimatedWebpConvertToGif(stickerBytes, v.Info.ID)
+				if webmSticker, cacheKey, err := utils.WaGetAnimatedStickerForTg(stickerBytes, stickerMsg.GetFileSha256()); err == nil {
+					sentMsg, err := tgBot.SendSticker(targetChatId, webmSticker, &gotgbot.SendStickerOpts{
+						ReplyToMessageId: replyToMsgId,
+						MessageThreadId:  threadId,
+						ReplyMarkup:      replymarkup,
+					})
+					if err == nil {
+						if sentMsg.Sticker != nil {
+							utils.StickerCacheSetTgFileId(cacheKey, sentMsg.Sticker.FileId)
+						}
+						database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+							targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
+						return
+					}
+					logger.Warn("failed to send animated sticker as a video sticker, sending it as a GIF",
+						zap.String("event_id", v.Info.ID),
+						zap.Error(err),
+					)
+				} else {
+					logger.Warn("failed to convert animated sticker to a video sticker, sending it as a GIF",
+						zap.String("event_id", v.Info.ID),
+						zap.Error(err),
+					)
+				}
+
+				gifBytes, err := utils.AnimatedWebpConvertToGif(stickerBytes)
 				if err != nil {
 					goto WEBP_TO_GIF_FAILED
 				}
@@ -845,43 +1192,43 @@ func MessageFromOthersEventHandler(text string, v *events.Message) {
 					File:     bytes.NewReader(gifBytes),
 				}
 
-				sentMsg, _ := tgBot.SendAnimation(cfg.Telegram.TargetChatID, fileToSend, &gotgbot.SendAnimationOpts{
+				sentMsg, _ := tgBot.SendAnimation(targetChatId, fileToSend, &gotgbot.SendAnimationOpts{
 					Caption:          bridgedText,
 					ReplyToMessageId: replyToMsgId,
 					MessageThreadId:  threadId,
 					ReplyMarkup:      replymarkup,
 				})
 				if sentMsg.MessageId != 0 {
-					database.MsgIdAddNewPair(v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
-						cfg.Telegram.TargetChatID, sentMsg.MessageId, sentMsg.MessageThreadId)
+					database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+						targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
 				}
 				return
 
 			}
 		WEBP_TO_GIF_FAILED:
-			sentMsg, _ := tgBot.SendSticker(cfg.Telegram.TargetChatID, stickerBytes, &gotgbot.SendStickerOpts{
+			sentMsg, _ := tgBot.SendSticker(targetChatId, stickerBytes, &gotgbot.SendStickerOpts{
 				ReplyToMessageId: replyToMsgId,
 				MessageThreadId:  threadId,
 				ReplyMarkup:      replymarkup,
 			})
 			if sentMsg.MessageId != 0 {
-				database.MsgIdAddNewPair(v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
-					cfg.Telegram.TargetChatID, sentMsg.MessageId, sentMsg.MessageThreadId)
+				database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+					targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
 			}
 		}
 
 	} else if v.Message.GetContactMessage() != nil {
 		contactMsg := v.Message.GetContactMessage()
 
-		if cfg.WhatsApp.SkipContacts {
-			bridgedText += "\n<b>Skipping contact because 'skip_contacts' set in config file</b>"
-			sentMsg, _ := tgBot.SendMessage(cfg.Telegram.TargetChatID, bridgedText, &gotgbot.SendMessageOpts{
+		if settings.SkipContacts {
+			bridgedText += "\n<b>Skipping contact because 'skip_contacts' is set for this chat</b>"
+			sentMsg, _ := tgBot.SendMessage(targetChatId, bridgedText, &gotgbot.SendMessageOpts{
 				ReplyToMessageId: replyToMsgId,
 				MessageThreadId:  threadId,
 			})
 			if sentMsg.MessageId != 0 {
-				database.MsgIdAddNewPair(v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
-					cfg.Telegram.TargetChatID, sentMsg.MessageId, sentMsg.MessageThreadId)
+				database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+					targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
 			}
 			return
 		}
@@ -890,18 +1237,18 @@ func MessageFromOthersEventHandler(text string, v *events.Message) {
 		card, err := decoder.Decode()
 		if err != nil {
 			bridgedText += "\n<b>Couldn't send the vCard as failed to parse it</b>"
-			sentMsg, _ := tgBot.SendMessage(cfg.Telegram.TargetChatID, bridgedText, &gotgbot.SendMessageOpts{
+			sentMsg, _ := tgBot.SendMessage(targetChatId, bridgedText, &gotgbot.SendMessageOpts{
 				ReplyToMessageId: replyToMsgId,
 				MessageThreadId:  threadId,
 			})
 			if sentMsg.MessageId != 0 {
-				database.MsgIdAddNewPair(v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
-					cfg.Telegram.TargetChatID, sentMsg.MessageId, sentMsg.MessageThreadId)
+				database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+					targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
 			}
 			return
 		}
 
-		sentMsg, _ := tgBot.SendContact(cfg.Telegram.TargetChatID, card.PreferredValue(goVCard.FieldTelephone), contactMsg.GetDisplayName(),
+		sentMsg, _ := tgBot.SendContact(targetChatId, card.PreferredValue(goVCard.FieldTelephone), contactMsg.GetDisplayName(),
 			&gotgbot.SendContactOpts{
 				Vcard:            contactMsg.GetVcard(),
 				ReplyToMessageId: replyToMsgId,
@@ -909,8 +1256,8 @@ func MessageFromOthersEventHandler(text string, v *events.Message) {
 				ReplyMarkup:      replymarkup,
 			})
 		if sentMsg.MessageId != 0 {
-			database.MsgIdAddNewPair(v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
-				cfg.Telegram.TargetChatID, sentMsg.MessageId, sentMsg.MessageThreadId)
+			database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+				targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
 		}
 		return
 
@@ -918,15 +1265,15 @@ func MessageFromOthersEventHandler(text string, v *events.Message) {
 
 		contactsMsg := v.Message.GetContactsArrayMessage()
 
-		if cfg.WhatsApp.SkipContacts {
-			bridgedText += "\n<b>Skipping contact array because 'skip_contacts' set in config file</b>"
-			sentMsg, _ := tgBot.SendMessage(cfg.Telegram.TargetChatID, bridgedText, &gotgbot.SendMessageOpts{
+		if settings.SkipContacts {
+			bridgedText += "\n<b>Skipping contact array because 'skip_contacts' is set for this chat</b>"
+			sentMsg, _ := tgBot.SendMessage(targetChatId, bridgedText, &gotgbot.SendMessageOpts{
 				ReplyToMessageId: replyToMsgId,
 				MessageThreadId:  threadId,
 			})
 			if sentMsg.MessageId != 0 {
-				database.MsgIdAddNewPair(v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
-					cfg.Telegram.TargetChatID, sentMsg.MessageId, sentMsg.MessageThreadId)
+				database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+					targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
 			}
 			return
 		}
@@ -934,15 +1281,18 @@ func MessageFromOthersEventHandler(text string, v *events.Message) {
 			decoder := goVCard.NewDecoder(bytes.NewReader([]byte(contactMsg.GetVcard())))
 			card, err := decoder.Decode()
 			if err != nil {
-				tgBot.SendMessage(cfg.Telegram.TargetChatID, "Couldn't send the vCard as failed to parse it",
+				errorMsg, err := tgBot.SendMessage(targetChatId, "Couldn't send the vCard as failed to parse it",
 					&gotgbot.SendMessageOpts{
 						ReplyToMessageId: replyToMsgId,
 						MessageThreadId:  threadId,
 					})
+				if err == nil {
+					extraTgMsgIds = append(extraTgMsgIds, errorMsg.MessageId)
+				}
 				continue
 			}
 
-			sentMsg, _ := tgBot.SendContact(cfg.Telegram.TargetChatID, card.PreferredValue(goVCard.FieldTelephone), contactMsg.GetDisplayName(),
+			sentMsg, _ := tgBot.SendContact(targetChatId, card.PreferredValue(goVCard.FieldTelephone), contactMsg.GetDisplayName(),
 				&gotgbot.SendContactOpts{
 					Vcard:            contactMsg.GetVcard(),
 					ReplyToMessageId: replyToMsgId,
@@ -950,8 +1300,9 @@ func MessageFromOthersEventHandler(text string, v *events.Message) {
 					ReplyMarkup:      replymarkup,
 				})
 			if sentMsg.MessageId != 0 {
-				database.MsgIdAddNewPair(v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
-					cfg.Telegram.TargetChatID, sentMsg.MessageId, sentMsg.MessageThreadId)
+				database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+					targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
+				extraTgMsgIds = append(extraTgMsgIds, sentMsg.MessageId)
 			}
 		}
 		return
@@ -960,27 +1311,27 @@ func MessageFromOthersEventHandler(text string, v *events.Message) {
 
 		locationMsg := v.Message.GetLocationMessage()
 
-		if cfg.WhatsApp.SkipLocations {
-			bridgedText += "\n<b>Skipping location because 'skip_locations' set in config file</b>"
-			sentMsg, _ := tgBot.SendMessage(cfg.Telegram.TargetChatID, bridgedText, &gotgbot.SendMessageOpts{
+		if settings.SkipLocations {
+			bridgedText += "\n<b>Skipping location because 'skip_locations' is set for this chat</b>"
+			sentMsg, _ := tgBot.SendMessage(targetChatId, bridgedText, &gotgbot.SendMessageOpts{
 				ReplyToMessageId: replyToMsgId,
 				MessageThreadId:  threadId,
 			})
 			if sentMsg.MessageId != 0 {
-				database.MsgIdAddNewPair(v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
-					cfg.Telegram.TargetChatID, sentMsg.MessageId, sentMsg.MessageThreadId)
+				database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+					targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
 			}
 			return
 		}
-		sentMsg, _ := tgBot.SendLocation(cfg.Telegram.TargetChatID, locationMsg.GetDegreesLatitude(), locationMsg.GetDegreesLongitude(),
+		sentMsg, _ := tgBot.SendLocation(targetChatId, locationMsg.GetDegreesLatitude(), locationMsg.GetDegreesLongitude(),
 			&gotgbot.SendLocationOpts{
 				HorizontalAccuracy: float64(locationMsg.GetAccuracyInMeters()),
 				ReplyToMessageId:   replyToMsgId,
 				MessageThreadId:    threadId,
 			})
 		if sentMsg.MessageId != 0 {
-			database.MsgIdAddNewPair(v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
-				cfg.Telegram.TargetChatID, sentMsg.MessageId, sentMsg.MessageThreadId)
+			database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+				targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
 		}
 
 		return
@@ -989,26 +1340,26 @@ func MessageFromOthersEventHandler(text string, v *events.Message) {
 
 		bridgedText += "\n<b>Shared their live location with you</b>"
 
-		if cfg.WhatsApp.SkipLocations {
-			bridgedText += "\n<b>Skipping live location because 'skip_locations' set in config file</b>"
-			sentMsg, _ := tgBot.SendMessage(cfg.Telegram.TargetChatID, bridgedText, &gotgbot.SendMessageOpts{
+		if settings.SkipLocations {
+			bridgedText += "\n<b>Skipping live location because 'skip_locations' is set for this chat</b>"
+			sentMsg, _ := tgBot.SendMessage(targetChatId, bridgedText, &gotgbot.SendMessageOpts{
 				ReplyToMessageId: replyToMsgId,
 				MessageThreadId:  threadId,
 			})
 			if sentMsg.MessageId != 0 {
-				database.MsgIdAddNewPair(v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
-					cfg.Telegram.TargetChatID, sentMsg.MessageId, sentMsg.MessageThreadId)
+				database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+					targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
 			}
 			return
 		}
 
-		sentMsg, _ := tgBot.SendMessage(cfg.Telegram.TargetChatID, bridgedText, &gotgbot.SendMessageOpts{
+		sentMsg, _ := tgBot.SendMessage(targetChatId, bridgedText, &gotgbot.SendMessageOpts{
 			ReplyToMessageId: replyToMsgId,
 			MessageThreadId:  threadId,
 		})
 		if sentMsg.MessageId != 0 {
-			database.MsgIdAddNewPair(v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
-				cfg.Telegram.TargetChatID, sentMsg.MessageId, sentMsg.MessageThreadId)
+			database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+				targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
 		}
 		return
 
@@ -1034,13 +1385,13 @@ func MessageFromOthersEventHandler(text string, v *events.Message) {
 			bridgedText += fmt.Sprintf("%v. %s\n", optionNum+1, html.EscapeString(option.GetOptionName()))
 		}
 
-		sentMsg, _ := tgBot.SendMessage(cfg.Telegram.TargetChatID, bridgedText, &gotgbot.SendMessageOpts{
+		sentMsg, _ := tgBot.SendMessage(targetChatId, bridgedText, &gotgbot.SendMessageOpts{
 			ReplyToMessageId: replyToMsgId,
 			MessageThreadId:  threadId,
 		})
 		if sentMsg.MessageId != 0 {
-			database.MsgIdAddNewPair(v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
-				cfg.Telegram.TargetChatID, sentMsg.MessageId, sentMsg.MessageThreadId)
+			database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+				targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
 		}
 		return
 
@@ -1069,37 +1420,58 @@ func MessageFromOthersEventHandler(text string, v *events.Message) {
 				)
 			}
 		}
-		sentMsg, _ := tgBot.SendMessage(cfg.Telegram.TargetChatID, bridgedText, &gotgbot.SendMessageOpts{
+		sentMsg, _ := tgBot.SendMessage(targetChatId, bridgedText, &gotgbot.SendMessageOpts{
 			ReplyToMessageId: replyToMsgId,
 			MessageThreadId:  threadId,
 		})
 		if sentMsg.MessageId != 0 {
-			database.MsgIdAddNewPair(v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
-				cfg.Telegram.TargetChatID, sentMsg.MessageId, sentMsg.MessageThreadId)
+			database.MsgIdAddNewPair(account.Name, v.Info.ID, v.Info.MessageSource.Sender.String(), v.Info.Chat.String(),
+				targetChatId, sentMsg.MessageId, sentMsg.MessageThreadId)
 		}
 		return
 	}
 }
 
-func CallOfferEventHandler(v *events.CallOffer) {
+func CallOfferEventHandler(account *state.WhatsAppAccount, v *events.CallOffer) {
 	var (
-		cfg   = state.State.Config
-		tgBot = state.State.TelegramBot
+		cfg          = state.State.Config
+		tgBot        = state.State.TelegramBot
+		targetChatId = utils.WaGetTargetChat(account, v.CallCreator.ToNonAD(), v.CallCreator)
 	)
 
 	// TODO : Check and handle group calls
 	callerName := utils.WaGetContactName(v.CallCreator)
 
-	callThreadId, err := utils.TgGetOrMakeThreadFromWa("status@broadcast", cfg.Telegram.TargetChatID, "Status/Calls/Tags [status@broadcast]")
+	callThreadId, err := utils.TgGetOrMakeSystemThread(account, utils.SystemTopicCalls, targetChatId)
 	if err != nil {
-		utils.TgSendErrorById(tgBot, cfg.Telegram.TargetChatID, 0, "Failed to create/retreive corresponding thread id for status/calls/tags", err)
+		utils.TgSendBridgeError(account, targetChatId, "Failed to create/retreive corresponding thread id for calls", err)
 		return
 	}
 
 	bridgeText := fmt.Sprintf("<b>#Calls</b>\nFrom: <b>%s</b>\n<b>%s</b>",
 		html.EscapeString(callerName), html.EscapeString(v.Timestamp.In(state.State.LocalLocation).Format(cfg.TimeFormat)))
 
-	utils.TgSendTextById(tgBot, cfg.Telegram.TargetChatID, callThreadId, bridgeText)
+	utils.TgSendTextById(tgBot, targetChatId, callThreadId, bridgeText)
+}
+
+func DisappearingSettingEventHandler(account *state.WhatsAppAccount, chat waTypes.JID, seconds uint32) {
+	logger := state.State.Logger
+	defer logger.Sync()
+
+	if err := utils.WaUpdateDisappearingTimer(account, chat, seconds, true); err != nil {
+		logger.Error("failed to update the disappearing messages timer",
+			zap.String("chat_jid", chat.String()),
+			zap.Error(err),
+		)
+	}
+}
+
+func GroupDisappearingEventHandler(account *state.WhatsAppAccount, v *events.GroupInfo) {
+	var seconds uint32
+	if v.Ephemeral.IsEphemeral {
+		seconds = v.Ephemeral.DisappearingTimer
+	}
+	DisappearingSettingEventHandler(account, v.JID, seconds)
 }
 
 func PushNameEventHandler(v *events.PushName) {
@@ -1115,7 +1487,7 @@ func PushNameEventHandler(v *events.PushName) {
 	database.ContactUpdatePushName(v.JID.User, v.NewPushName)
 }
 
-func RevokedMessageEventHandler(v *events.Message) {
+func RevokedMessageEventHandler(account *state.WhatsAppAccount, v *events.Message) {
 	var (
 		cfg         = state.State.Config
 		tgBot       = state.State.TelegramBot
@@ -1137,16 +1509,25 @@ func RevokedMessageEventHandler(v *events.Message) {
 		deleterName = utils.WaGetContactName(deleter)
 	}
 
-	tgChatId, tgThreadId, tgMsgId, err := database.MsgIdGetTgFromWa(waMsgId, waChatId)
+	tgChatId, tgThreadId, tgMsgId, err := database.MsgIdGetTgFromWa(account.Name, waMsgId, waChatId)
 	if err != nil || tgChatId == 0 || tgThreadId == 0 || tgMsgId == 0 {
 		return
 	}
 
-	tgBot.SendMessage(tgChatId, fmt.Sprintf(
-		"<b>This message was revoked by %s</b>",
-		html.EscapeString(deleterName),
-	), &gotgbot.SendMessageOpts{
-		MessageThreadId:  tgThreadId,
-		ReplyToMessageId: tgMsgId,
+	revocationsThreadId, err := utils.TgGetOrMakeSystemThread(account, utils.SystemTopicRevocations, tgChatId)
+	if err != nil {
+		utils.TgSendBridgeError(account, tgChatId, "Failed to create/retreive corresponding thread id for revocations", err)
+		return
+	}
+
+	revokedText := fmt.Sprintf("<b>#Revoked</b>\nBy: <b>%s</b>\n", html.EscapeString(deleterName))
+	if v.Info.IsGroup {
+		revokedText += fmt.Sprintf("Chat: <b>%s</b>\n", html.EscapeString(utils.WaGetGroupName(account, v.Info.Chat)))
+	}
+	revokedText += fmt.Sprintf("<a href=\"%s\">Go to message</a>", utils.TgGetMessageLink(tgChatId, tgThreadId, tgMsgId))
+
+	tgBot.SendMessage(tgChatId, revokedText, &gotgbot.SendMessageOpts{
+		MessageThreadId:       revocationsThreadId,
+		DisableWebPagePreview: true,
 	})
 }
@@ -0,0 +1,277 @@
+package whatsapp
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"watgbridge/state"
+	"watgbridge/utils"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types/events"
+	"go.uber.org/zap"
+)
+
+// Number of consecutive keepalive failures after which the connection is
+// considered dead and is restarted instead of waiting for the socket to notice
+const keepAliveFailuresBeforeReconnect = 3
+
+var isShuttingDown int32
+
+type accountSupervisor struct {
+	isReconnecting  int32
+	isLoggingIn     int32
+	hasAlertedOwner int32
+}
+
+// Filled once while creating the clients, hence read without locking
+var supervisors = make(map[string]*accountSupervisor)
+
+// alertHeader is the first line of the alerts sent to the owner about an account
+func alertHeader(account *state.WhatsAppAccount) string {
+	return fmt.Sprintf("<b>#WhatsApp</b> (<code>%s</code>)\n", html.EscapeString(account.Name))
+}
+
+func ConnectedEventHandler(account *state.WhatsAppAccount, v *events.Connected) {
+	var (
+		logger     = state.State.Logger
+		tgBot      = state.State.TelegramBot
+		cfg        = state.State.Config
+		waClient   = account.Client
+		supervisor = supervisors[account.Name]
+	)
+	defer logger.Sync()
+
+	_, _, failures := account.Connection.GetStatus()
+	account.Connection.SetStatus(state.WaConnected, 0)
+
+	logger.Info("connected to WhatsApp",
+		zap.String("account", account.Name),
+		zap.String("jid", waClient.Store.ID.String()),
+		zap.Int("failed_attempts", failures),
+	)
+
+	if atomic.CompareAndSwapInt32(&supervisor.hasAlertedOwner, 1, 0) {
+		utils.TgSendTextById(tgBot, cfg.Telegram.OwnerID, 0,
+			fmt.Sprintf("%sConnection restored after <b>%v</b> failed attempts", alertHeader(account), failures))
+	}
+}
+
+func DisconnectedEventHandler(account *state.WhatsAppAccount, v *events.Disconnected) {
+	logger := state.State.Logger
+	defer logger.Sync()
+
+	logger.Warn("disconnected from WhatsApp by the server",
+		zap.String("account", account.Name),
+	)
+	StartReconnecting(account)
+}
+
+func KeepAliveTimeoutEventHandler(account *state.WhatsAppAccount, v *events.KeepAliveTimeout) {
+	var (
+		logger   = state.State.Logger
+		waClient = account.Client
+	)
+	defer logger.Sync()
+
+	logger.Warn("WhatsApp keepalive timed out",
+		zap.String("account", account.Name),
+		zap.Int("error_count", v.ErrorCount),
+		zap.Time("last_success", v.LastSuccess),
+	)
+
+	if v.ErrorCount >= keepAliveFailuresBeforeReconnect {
+		waClient.Disconnect()
+		StartReconnecting(account)
+	}
+}
+
+func StreamReplacedEventHandler(account *state.WhatsAppAccount, v *events.StreamReplaced) {
+	var (
+		logger = state.State.Logger
+		tgBot  = state.State.TelegramBot
+		cfg    = state.State.Config
+	)
+	defer logger.Sync()
+
+	logger.Warn("WhatsApp stream was replaced by another client",
+		zap.String("account", account.Name),
+	)
+	account.Connection.SetStatus(state.WaStreamReplaced, 0)
+
+	utils.TgSendTextById(tgBot, cfg.Telegram.OwnerID, 0,
+		alertHeader(account)+"The connection was replaced by another client using the same session. "+
+			"Make sure only one instance of the bridge is running, then use /restartwa")
+}
+
+func TemporaryBanEventHandler(account *state.WhatsAppAccount, v *events.TemporaryBan) {
+	var (
+		logger = state.State.Logger
+		tgBot  = state.State.TelegramBot
+		cfg    = state.State.Config
+	)
+	defer logger.Sync()
+
+	logger.Warn("WhatsApp account temporarily banned",
+		zap.String("account", account.Name),
+		zap.Int("code", int(v.Code)),
+		zap.Duration("expire", v.Expire),
+	)
+	account.Connection.SetStatus(state.WaTemporarilyBanned, 0)
+
+	alertText := alertHeader(account) + html.EscapeString(v.String())
+	if v.Expire > 0 {
+		alertText += "\nWill try to reconnect once the ban expires"
+		time.AfterFunc(v.Expire, func() { StartReconnecting(account) })
+	}
+	utils.TgSendTextById(tgBot, cfg.Telegram.OwnerID, 0, alertText)
+}
+
+func LoggedOutEventHandler(account *state.WhatsAppAccount, v *events.LoggedOut) {
+	var (
+		logger     = state.State.Logger
+		tgBot      = state.State.TelegramBot
+		cfg        = state.State.Config
+		waClient   = account.Client
+		supervisor = supervisors[account.Name]
+	)
+	defer logger.Sync()
+
+	logger.Warn("logged out from WhatsApp",
+		zap.String("account", account.Name),
+		zap.Bool("on_connect", v.OnConnect),
+		zap.String("reason", v.Reason.String()),
+	)
+	account.Connection.SetStatus(state.WaLoggedOut, 0)
+
+	utils.TgSendTextById(tgBot, cfg.Telegram.OwnerID, 0,
+		fmt.Sprintf("%sLogged out of WhatsApp (<code>%s</code>), starting the login process again",
+			alertHeader(account), html.EscapeString(v.Reason.String())))
+
+	if !atomic.CompareAndSwapInt32(&supervisor.isLoggingIn, 0, 1) {
+		return
+	}
+	go func() {
+		defer atomic.StoreInt32(&supervisor.isLoggingIn, 0)
+
+		// The session is deleted by whatsmeow after emitting the event, so the
+		// old socket has to be closed before a new one can be used for pairing
+		waClient.Disconnect()
+		for i := 0; i < 10 && waClient.Store.ID != nil; i++ {
+			time.Sleep(time.Second)
+		}
+		if waClient.Store.ID != nil {
+			if err := waClient.Store.Delete(); err != nil {
+				utils.TgSendErrorById(tgBot, cfg.Telegram.OwnerID, 0, "Failed to delete the old WhatsApp session, restart the bridge to login again", err)
+				return
+			}
+		}
+
+		if err := LoginWithQRCode(account); err != nil {
+			logger.Error("failed to login to WhatsApp again",
+				zap.String("account", account.Name),
+				zap.Error(err),
+			)
+			utils.TgSendErrorById(tgBot, cfg.Telegram.OwnerID, 0, "Failed to login to WhatsApp again, restart the bridge to retry", err)
+		}
+	}()
+}
+
+// StartReconnecting keeps trying to connect the account to WhatsApp with an exponential
+// backoff till it succeeds. It is a no-op if a reconnection loop is already running.
+func StartReconnecting(account *state.WhatsAppAccount) {
+	supervisor := supervisors[account.Name]
+	if atomic.LoadInt32(&isShuttingDown) == 1 ||
+		!atomic.CompareAndSwapInt32(&supervisor.isReconnecting, 0, 1) {
+		return
+	}
+
+	go func() {
+		defer atomic.StoreInt32(&supervisor.isReconnecting, 0)
+
+		var (
+			cfg      = state.State.Config
+			logger   = state.State.Logger
+			tgBot    = state.State.TelegramBot
+			waClient = account.Client
+		)
+		defer logger.Sync()
+
+		for attempt := 1; ; attempt++ {
+			if waClient.Store.ID == nil {
+				// Logged out, the login process will take over
+				return
+			}
+			if atomic.LoadInt32(&isShuttingDown) == 1 {
+				return
+			}
+
+			account.Connection.SetStatus(state.WaReconnecting, attempt-1)
+			delay := reconnectDelay(attempt)
+			logger.Info("reconnecting to WhatsApp",
+				zap.String("account", account.Name),
+				zap.Int("attempt", attempt),
+				zap.Duration("delay", delay),
+			)
+			time.Sleep(delay)
+			if atomic.LoadInt32(&isShuttingDown) == 1 {
+				return
+			}
+
+			err := waClient.Connect()
+			if err == nil || errors.Is(err, whatsmeow.ErrAlreadyConnected) {
+				return
+			}
+
+			logger.Error("failed to reconnect to WhatsApp",
+				zap.String("account", account.Name),
+				zap.Int("attempt", attempt),
+				zap.Error(err),
+			)
+			account.Connection.SetStatus(state.WaReconnecting, attempt)
+
+			if attempt == cfg.WhatsApp.Reconnect.AlertAfterFailures &&
+				atomic.CompareAndSwapInt32(&supervisor.hasAlertedOwner, 0, 1) {
+				utils.TgSendErrorById(tgBot, cfg.Telegram.OwnerID, 0,
+					fmt.Sprintf("%sFailed to reconnect to WhatsApp %v times in a row, still retrying", alertHeader(account), attempt), err)
+			}
+		}
+	}()
+}
+
+// Disconnect closes the connections of all the accounts to WhatsApp for good,
+// stopping any further reconnection attempts
+func Disconnect() {
+	atomic.StoreInt32(&isShuttingDown, 1)
+	for _, account := range state.State.WhatsAppAccounts {
+		account.Client.Disconnect()
+	}
+}
+
+func reconnectDelay(attempt int) time.Duration {
+	var (
+		cfg      = state.State.Config
+		delay    = time.Duration(cfg.WhatsApp.Reconnect.InitialDelaySeconds) * time.Second
+		maxDelay = time.Duration(cfg.WhatsApp.Reconnect.MaxDelaySeconds) * time.Second
+	)
+
+	if delay <= 0 {
+		delay = time.Second
+	}
+	for i := 1; i < attempt; i++ {
+		// Without a cap the delay keeps doubling, only stopping short of overflowing
+		if (maxDelay > 0 && delay >= maxDelay) || delay > math.MaxInt64/2 {
+			break
+		}
+		delay *= 2
+	}
+	if maxDelay > 0 && delay > maxDelay {
+		delay = maxDelay
+	}
+
+	return delay
+}